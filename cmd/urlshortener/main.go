@@ -1,17 +1,48 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 
 	"github.com/Kerseee/urlshortener/config"
 	"github.com/Kerseee/urlshortener/internal/urlshortener"
 )
 
+// Usage:
+//
+//	urlshortener [flags]                        serve http requests
+//	urlshortener [flags] create-api-key <name>  create an API key and print it
+//	urlshortener [flags] purge                  purge the expired URLs and print the number of purged URLs
 func main() {
 	cfg := config.New()
 	app, err := urlshortener.New(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Fatal(app.Serve())
+
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+		if err := app.Serve(); err != nil {
+			log.Fatal(err)
+		}
+	case "create-api-key":
+		key, err := app.CreateAPIKey(flag.Arg(1))
+		app.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(key)
+	case "purge":
+		n, err := app.PurgeExpired(context.Background())
+		app.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(n)
+	default:
+		app.Close()
+		log.Fatalf("unknown command %q", cmd)
+	}
 }
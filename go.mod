@@ -1,5 +1,8 @@
 module github.com/Kerseee/urlshortener
 
-go 1.17
+go 1.21
 
-require github.com/lib/pq v1.10.4
+require (
+	github.com/lib/pq v1.10.4
+	golang.org/x/crypto v0.31.0
+)
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrDuplicateAPIKey is returned when inserting an API key whose hash already exists.
+var ErrDuplicateAPIKey = errors.New("duplicate API key")
+
+// APIKeyModel is a wrapper of a db connection pool for the api_keys table.
+type APIKeyModel struct {
+	DB           *sql.DB
+	QueryTimeOut time.Duration
+}
+
+// APIKey holds an entry of the table "api_keys" in the database.
+// Only the SHA-256 hash of the key is stored.
+type APIKey struct {
+	ID        int64
+	Name      string
+	Hash      []byte
+	CreatedAt time.Time
+}
+
+// NewAPIKey generates a random API key named name.
+// It returns the key to be stored and the plaintext key to be given to the client.
+func NewAPIKey(name string) (*APIKey, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	plaintext := base64.RawURLEncoding.EncodeToString(b)
+	k := &APIKey{
+		Name:      name,
+		Hash:      HashAPIKey(plaintext),
+		CreatedAt: time.Now(),
+	}
+	return k, plaintext, nil
+}
+
+// HashAPIKey returns the SHA-256 hash of the plaintext API key.
+func HashAPIKey(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// Insert inserts an APIKey into the api_keys table in the database and populates k.ID.
+func (m *APIKeyModel) Insert(k *APIKey) error {
+	// Prepare the query and arguments.
+	query := `
+		INSERT INTO api_keys(name, key_hash, created_at)
+		VALUES ($1, $2, $3)
+		RETURNING id`
+	args := []interface{}{k.Name, k.Hash, k.CreatedAt.UTC()}
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	// Execute the query.
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&k.ID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), errMsgViolateUniquePQ):
+			return ErrDuplicateAPIKey
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByHash returns the APIKey having the hash.
+func (m *APIKeyModel) GetByHash(hash []byte) (*APIKey, error) {
+	// Prepare the query.
+	query := `
+		SELECT id, name, key_hash, created_at
+		FROM api_keys
+		WHERE key_hash = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	// Execute the query.
+	var k APIKey
+	err := m.DB.QueryRowContext(ctx, query, hash).Scan(&k.ID, &k.Name, &k.Hash, &k.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &k, nil
+}
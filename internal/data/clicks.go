@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ClickModel is a wrapper of a db connection pool for the clicks table.
+type ClickModel struct {
+	DB           *sql.DB
+	QueryTimeOut time.Duration
+}
+
+// Click holds an entry of the table "clicks" in the database,
+// which records a successful redirect of a shortened URL.
+type Click struct {
+	ID        int64
+	URLID     int64 // id of the redirected URL in the urls table
+	ShortPath string
+	ClickedAt time.Time
+	Referrer  string
+	UserAgent string
+	ClientIP  string // coarse client IP, like "203.0.113.0"
+}
+
+// DailyClicks is the number of clicks on a day (in UTC).
+type DailyClicks struct {
+	Date   string `json:"date"` // formatted as "2006-01-02"
+	Clicks int64  `json:"clicks"`
+}
+
+// ReferrerClicks is the number of clicks from a referrer.
+type ReferrerClicks struct {
+	Referrer string `json:"referrer"`
+	Clicks   int64  `json:"clicks"`
+}
+
+// ClickStats summarizes the clicks of a shortened URL.
+type ClickStats struct {
+	Total     int64            `json:"total"`
+	Daily     []DailyClicks    `json:"daily"`
+	Referrers []ReferrerClicks `json:"referrers"`
+}
+
+// Insert inserts a Click into the clicks table in the database.
+func (m *ClickModel) Insert(c *Click) error {
+	// Prepare the query and arguments.
+	query := `
+		INSERT INTO clicks(url_id, short_url, clicked_at, referrer, user_agent, client_ip)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	args := []interface{}{c.URLID, c.ShortPath, c.ClickedAt.UTC(), c.Referrer, c.UserAgent, c.ClientIP}
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	// Execute the query.
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&c.ID)
+}
+
+// InsertBatch inserts clicks into the clicks table in the database in a single transaction
+// with the COPY protocol. The IDs of the inserted clicks are not populated.
+func (m *ClickModel) InsertBatch(clicks []*Click) (err error) {
+	if len(clicks) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	// Begin a transaction, which is required by COPY.
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Copy the clicks into the table.
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("clicks", "url_id", "short_url", "clicked_at", "referrer", "user_agent", "client_ip"))
+	if err != nil {
+		return err
+	}
+	for _, c := range clicks {
+		_, err = stmt.ExecContext(ctx, c.URLID, c.ShortPath, c.ClickedAt.UTC(), c.Referrer, c.UserAgent, c.ClientIP)
+		if err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err = stmt.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Stats returns the total clicks and the per-day and per-referrer breakdowns
+// of the URL having the id urlID.
+func (m *ClickModel) Stats(urlID int64) (*ClickStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	stats := ClickStats{
+		Daily:     []DailyClicks{},
+		Referrers: []ReferrerClicks{},
+	}
+
+	// Count the clicks per day.
+	query := `
+		SELECT to_char(clicked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, count(*)
+		FROM clicks
+		WHERE url_id = $1
+		GROUP BY day
+		ORDER BY day`
+	rows, err := m.DB.QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d DailyClicks
+		if err := rows.Scan(&d.Date, &d.Clicks); err != nil {
+			return nil, err
+		}
+		stats.Total += d.Clicks
+		stats.Daily = append(stats.Daily, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Count the clicks per referrer.
+	query = `
+		SELECT referrer, count(*) AS clicks
+		FROM clicks
+		WHERE url_id = $1
+		GROUP BY referrer
+		ORDER BY clicks DESC, referrer`
+	rows, err = m.DB.QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ref ReferrerClicks
+		if err := rows.Scan(&ref.Referrer, &ref.Clicks); err != nil {
+			return nil, err
+		}
+		stats.Referrers = append(stats.Referrers, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,140 @@
+package data
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// FileStore keeps the shortened URLs and the API keys in memory and persists every change into an append-only file,
+// which is replayed when the store is opened. It is safe for concurrent use.
+//
+// The file is compacted when the store is opened, so it only grows while the store is open.
+type FileStore struct {
+	*MemoryStore
+	file *os.File
+}
+
+// OpenFileStore opens the FileStore persisted in the file at path, creating the file if it does not exist.
+// Only one FileStore should open a file at a time.
+func OpenFileStore(path string) (*FileStore, error) {
+	// Replay the changes in the file.
+	m := NewMemoryStore()
+	n, err := replayFile(m, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compact the file if it contains overwritten or deleted URLs.
+	if n > len(m.urls)+len(m.keys) {
+		if err := compactFile(m, path); err != nil {
+			return nil, err
+		}
+	}
+
+	// Append the following changes to the file.
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{MemoryStore: m, file: file}
+	m.persist = s.append
+	return s, nil
+}
+
+// Close closes the file of the store.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.persist = func(c change) error { return os.ErrClosed }
+	return s.file.Close()
+}
+
+// append writes c into the file and flushes it to the disk.
+func (s *FileStore) append(c change) error {
+	line, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// replayFile applies the changes in the file at path to m and returns the number of changes of the URLs and the API keys.
+// A missing file is treated as an empty one.
+func replayFile(m *MemoryStore, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	n := 0
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for line := 1; ; line++ {
+		var c change
+		err := decoder.Decode(&c)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: corrupted change %d: %w", path, line, err)
+		}
+		valid := c.Op == opDelete || c.Op == opNextID || (c.Op == opPut && c.URL != nil) || (c.Op == opPutKey && c.Key != nil)
+		if !valid {
+			return 0, fmt.Errorf("%s: invalid change %d", path, line)
+		}
+		m.replay(c)
+		if c.Op != opNextID {
+			n++
+		}
+	}
+}
+
+// compactFile atomically replaces the file at path with the URLs and the API keys in m.
+// The next id of the URLs is kept as well, so that the ids of the deleted URLs are not reused.
+func compactFile(m *MemoryStore, path string) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for _, k := range m.keys {
+		if err := encoder.Encode(change{Op: opPutKey, ID: k.ID, Key: k}); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	for id, u := range m.urls {
+		if err := encoder.Encode(change{Op: opPut, ID: id, URL: u}); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := encoder.Encode(change{Op: opNextID, ID: m.nextID}); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,162 @@
+package data
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+	expireAt := time.Date(2032, time.December, 22, 12, 0, 0, 0, time.UTC)
+
+	// Modify the store.
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"aaaa", "bbbb", "cccc"} {
+		if err := s.Insert(&URL{URL: "https://" + p + ".com", ShortPath: p, ExpireAt: expireAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u, _ := s.Get("bbbb")
+	u.URL = "https://updated.com"
+	if err := s.Update(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("cccc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Insert(&URL{ShortPath: "dddd"}); err == nil {
+		t.Error("want error inserting into a closed store, got nil")
+	}
+
+	// Reopen the store.
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got, err := s.Get("aaaa"); err != nil || got.URL != "https://aaaa.com" || !got.ExpireAt.Equal(expireAt) {
+		t.Errorf("want inserted URL replayed, got %+v, %v", got, err)
+	}
+	if got, err := s.Get("bbbb"); err != nil || got.URL != "https://updated.com" {
+		t.Errorf("want updated URL replayed, got %+v, %v", got, err)
+	}
+	if _, err := s.Get("cccc"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("want deleted URL not replayed, got %v", err)
+	}
+	if _, err := s.Get("dddd"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("want failed insert not replayed, got %v", err)
+	}
+
+	// The file is compacted into the 2 remaining URLs and the next id.
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(content), "\n"); n != 3 {
+		t.Errorf("want 3 lines in the compacted file, got %d", n)
+	}
+
+	// New IDs do not collide with the replayed ones.
+	u = &URL{URL: "https://eeee.com", ShortPath: "eeee", ExpireAt: expireAt}
+	if err := s.Insert(u); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID <= 2 {
+		t.Errorf("want new id greater than 2, got %d", u.ID)
+	}
+}
+
+func TestFileStoreNextIDAfterCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"1", "2"} {
+		if err := s.Insert(&URL{URL: "https://example.com/" + p, ShortPath: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The id of the deleted URL is not reused after the compaction drops its changes.
+	for i := 0; i < 2; i++ {
+		s, err = OpenFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if id, err := s.NextID(); err != nil || id != 3 {
+		t.Errorf("want next id 3, got %d, %v", id, err)
+	}
+}
+
+func TestOpenFileStoreCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+	if err := os.WriteFile(path, []byte(`{"op":"put","id":1,"url":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFileStore(path); err == nil {
+		t.Error("want error opening a corrupted file, got nil")
+	}
+}
+
+func TestFileStoreAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, plaintext, err := NewAPIKey("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.APIKeys().Insert(k); err != nil {
+		t.Fatal(err)
+	}
+	u := &URL{URL: "https://github.com", ShortPath: "aaaa", OwnerID: k.ID}
+	if err := s.Insert(u); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The API key and the owner are replayed.
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if got, err := s.APIKeys().GetByHash(HashAPIKey(plaintext)); err != nil || got.ID != k.ID {
+		t.Errorf("want API key replayed, got %+v, %v", got, err)
+	}
+	if got, err := s.Get("aaaa"); err != nil || got.OwnerID != k.ID {
+		t.Errorf("want owner %d replayed, got %+v, %v", k.ID, got, err)
+	}
+}
@@ -0,0 +1,354 @@
+package data
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the shortened URLs, the API keys and the clicks in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	urls   map[int64]*URL   // id -> URL
+	paths  map[string]int64 // short path -> id
+	nextID int64
+
+	keys      map[string]*APIKey // hash -> API key
+	nextKeyID int64
+
+	// clicks keeps the clicks of the URLs, which are dropped when their URL is removed.
+	clicks *MemoryClickStore
+
+	// persist is called with a change before the change is applied.
+	// The change is discarded if persist returns an error.
+	// It is nil for a pure in-memory store.
+	persist func(c change) error
+}
+
+// A change is a modification of a MemoryStore.
+type change struct {
+	Op  string  `json:"op"`            // opPut, opDelete, opPutKey or opNextID
+	ID  int64   `json:"id"`            // id of the URL or the API key, or the next id of the URLs for opNextID
+	URL *URL    `json:"url,omitempty"` // the URL to put, nil for opDelete and opPutKey
+	Key *APIKey `json:"key,omitempty"` // the API key to put for opPutKey
+}
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+	opPutKey = "put_key"
+	opNextID = "next_id" // keeps the ids of the deleted URLs from being reused after compacting a FileStore
+)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		urls:   make(map[int64]*URL),
+		paths:  make(map[string]int64),
+		nextID: 1,
+
+		keys:      make(map[string]*APIKey),
+		nextKeyID: 1,
+
+		clicks: NewMemoryClickStore(),
+	}
+}
+
+// Get returns a URL instance based on given shortPath.
+func (m *MemoryStore) Get(s string) (*URL, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.paths[s]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	u := *m.urls[id]
+	return &u, nil
+}
+
+// Insert inserts a URL into the store, populates u.ID if it is 0, and sets u.CreatedAt.
+// An expired URL having the same short path is replaced.
+func (m *MemoryStore) Insert(u *URL) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The expired URL having the same short path is replaced by u in the same change,
+	// so that the short path is only reclaimed if u is inserted.
+	if id, ok := m.paths[u.ShortPath]; ok && !m.urls[id].Expired(time.Now()) {
+		return ErrDuplicateShortUrl
+	}
+	stored := *u
+	stored.ClickCount = 0
+	stored.CreatedAt = time.Now().UTC()
+	if stored.ID == 0 {
+		stored.ID = m.nextID
+	}
+	if err := m.apply(change{Op: opPut, ID: stored.ID, URL: &stored}); err != nil {
+		return err
+	}
+	u.ID = stored.ID
+	u.CreatedAt = stored.CreatedAt
+	return nil
+}
+
+// InsertBatch inserts the URLs in us into the store one by one, and returns the error of inserting each URL.
+func (m *MemoryStore) InsertBatch(us []*URL) []error {
+	errs := make([]error, len(us))
+	for i, u := range us {
+		errs[i] = m.Insert(u)
+	}
+	return errs
+}
+
+// NextID reserves and returns the next id of the URLs.
+// The ids of the inserted URLs, including the deleted ones, are never returned again.
+// The reservations are not persisted, so an id reserved but never inserted may be returned again
+// after reopening a FileStore.
+func (m *MemoryStore) NextID() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextID
+	m.nextID++
+	return id, nil
+}
+
+// Update updates the URL having the id u.ID in the store.
+func (m *MemoryStore) Update(u *URL) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.urls[u.ID]; !ok {
+		return ErrRecordNotFound
+	}
+	if id, ok := m.paths[u.ShortPath]; ok && id != u.ID {
+		return ErrDuplicateShortUrl
+	}
+	stored := *u
+	stored.ClickCount = m.urls[u.ID].ClickCount
+	stored.CreatedAt = m.urls[u.ID].CreatedAt
+	return m.apply(change{Op: opPut, ID: u.ID, URL: &stored})
+}
+
+// UseClick counts a click of the URL having the id in the store, unless its ClickCount has reached its MaxClicks.
+func (m *MemoryStore) UseClick(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.urls[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	if u.MaxClicks > 0 && u.ClickCount >= u.MaxClicks {
+		return ErrClickLimitReached
+	}
+	stored := *u
+	stored.ClickCount++
+	return m.apply(change{Op: opPut, ID: id, URL: &stored})
+}
+
+// Delete deletes the URL having the shortPath s from the store.
+func (m *MemoryStore) Delete(s string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.paths[s]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	return m.apply(change{Op: opDelete, ID: id})
+}
+
+// DeleteExpired deletes at most limit URLs expired before the time before from the store,
+// and returns the number of deleted URLs.
+func (m *MemoryStore) DeleteExpired(before time.Time, limit int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var n int64
+	for id, u := range m.urls {
+		if n >= int64(limit) {
+			break
+		}
+		if !u.Expired(before) {
+			continue
+		}
+		if err := m.apply(change{Op: opDelete, ID: id}); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// apply persists and applies c. m.mu must be held.
+func (m *MemoryStore) apply(c change) error {
+	if m.persist != nil {
+		if err := m.persist(c); err != nil {
+			return err
+		}
+	}
+	m.replay(c)
+	return nil
+}
+
+// replay applies c without persisting it. m.mu must be held.
+func (m *MemoryStore) replay(c change) {
+	if c.Op == opPutKey {
+		m.keys[string(c.Key.Hash)] = c.Key
+		if c.ID >= m.nextKeyID {
+			m.nextKeyID = c.ID + 1
+		}
+		return
+	}
+	if c.Op == opNextID {
+		if c.ID > m.nextID {
+			m.nextID = c.ID
+		}
+		return
+	}
+
+	if old, ok := m.urls[c.ID]; ok {
+		delete(m.paths, old.ShortPath)
+		delete(m.urls, c.ID)
+	}
+	if c.Op == opDelete {
+		m.clicks.deleteURL(c.ID)
+	}
+	if c.Op == opPut {
+		// A put replaces the URL having the same short path, which is reclaimed by Insert.
+		if id, ok := m.paths[c.URL.ShortPath]; ok && id != c.ID {
+			delete(m.urls, id)
+			m.clicks.deleteURL(id)
+		}
+		m.urls[c.ID] = c.URL
+		m.paths[c.URL.ShortPath] = c.ID
+	}
+	if c.ID >= m.nextID {
+		m.nextID = c.ID + 1
+	}
+}
+
+// APIKeys returns the APIKeyStore keeping the API keys in m.
+func (m *MemoryStore) APIKeys() APIKeyStore {
+	return memoryAPIKeys{m}
+}
+
+// Clicks returns the MemoryClickStore keeping the clicks of the URLs in m.
+// The clicks of a URL are dropped when the URL is deleted or its short path is reclaimed.
+func (m *MemoryStore) Clicks() *MemoryClickStore {
+	return m.clicks
+}
+
+// memoryAPIKeys is the APIKeyStore of a MemoryStore.
+type memoryAPIKeys struct {
+	m *MemoryStore
+}
+
+// Insert inserts an APIKey into the store and populates k.ID.
+func (s memoryAPIKeys) Insert(k *APIKey) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	if _, ok := s.m.keys[string(k.Hash)]; ok {
+		return ErrDuplicateAPIKey
+	}
+	stored := *k
+	stored.ID = s.m.nextKeyID
+	if err := s.m.apply(change{Op: opPutKey, ID: stored.ID, Key: &stored}); err != nil {
+		return err
+	}
+	k.ID = stored.ID
+	return nil
+}
+
+// GetByHash returns the APIKey having the hash.
+func (s memoryAPIKeys) GetByHash(hash []byte) (*APIKey, error) {
+	s.m.mu.RLock()
+	defer s.m.mu.RUnlock()
+
+	k, ok := s.m.keys[string(hash)]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	stored := *k
+	return &stored, nil
+}
+
+// MemoryClickStore keeps the clicks in memory. It is safe for concurrent use.
+type MemoryClickStore struct {
+	mu     sync.RWMutex
+	clicks map[int64][]Click // url id -> clicks
+	nextID int64
+}
+
+// NewMemoryClickStore creates an empty MemoryClickStore.
+func NewMemoryClickStore() *MemoryClickStore {
+	return &MemoryClickStore{
+		clicks: make(map[int64][]Click),
+		nextID: 1,
+	}
+}
+
+// InsertBatch inserts clicks into the store.
+func (m *MemoryClickStore) InsertBatch(clicks []*Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range clicks {
+		stored := *c
+		stored.ID = m.nextID
+		m.nextID++
+		m.clicks[c.URLID] = append(m.clicks[c.URLID], stored)
+	}
+	return nil
+}
+
+// deleteURL drops the clicks of the URL having the id urlID.
+func (m *MemoryClickStore) deleteURL(urlID int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.clicks, urlID)
+}
+
+// Stats returns the total clicks and the per-day and per-referrer breakdowns
+// of the URL having the id urlID.
+func (m *MemoryClickStore) Stats(urlID int64) (*ClickStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	days := make(map[string]int64)
+	referrers := make(map[string]int64)
+	for _, c := range m.clicks[urlID] {
+		days[c.ClickedAt.UTC().Format(dateLayout)]++
+		referrers[c.Referrer]++
+	}
+
+	stats := ClickStats{
+		Total:     int64(len(m.clicks[urlID])),
+		Daily:     make([]DailyClicks, 0, len(days)),
+		Referrers: make([]ReferrerClicks, 0, len(referrers)),
+	}
+	for day, n := range days {
+		stats.Daily = append(stats.Daily, DailyClicks{Date: day, Clicks: n})
+	}
+	sort.Slice(stats.Daily, func(i, j int) bool {
+		return stats.Daily[i].Date < stats.Daily[j].Date
+	})
+	for ref, n := range referrers {
+		stats.Referrers = append(stats.Referrers, ReferrerClicks{Referrer: ref, Clicks: n})
+	}
+	sort.Slice(stats.Referrers, func(i, j int) bool {
+		a, b := stats.Referrers[i], stats.Referrers[j]
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.Referrer < b.Referrer
+	})
+	return &stats, nil
+}
+
+// dateLayout is the layout of DailyClicks.Date.
+const dateLayout = "2006-01-02"
@@ -0,0 +1,355 @@
+package data
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	m := NewMemoryStore()
+	expireAt := time.Date(2032, time.December, 22, 12, 0, 0, 0, time.UTC)
+
+	// Insert.
+	u := &URL{URL: "https://google.com", ShortPath: "BQRvJsg-", ExpireAt: expireAt}
+	if err := m.Insert(u); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 1 {
+		t.Errorf("want id 1, got %d", u.ID)
+	}
+	err := m.Insert(&URL{URL: "https://github.com", ShortPath: "BQRvJsg-", ExpireAt: expireAt})
+	if !errors.Is(err, ErrDuplicateShortUrl) {
+		t.Errorf("want ErrDuplicateShortUrl, got %v", err)
+	}
+
+	// Get returns a copy.
+	got, err := m.Get("BQRvJsg-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.URL != u.URL || !got.ExpireAt.Equal(expireAt) {
+		t.Errorf("want %+v, got %+v", u, got)
+	}
+	got.URL = "https://mutated.com"
+	if again, _ := m.Get("BQRvJsg-"); again.URL != u.URL {
+		t.Errorf("want stored URL not mutated, got %q", again.URL)
+	}
+
+	// Update, including the short path.
+	got.ShortPath = "spring-sale"
+	if err := m.Update(got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get("BQRvJsg-"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("want old short path removed, got %v", err)
+	}
+	if updated, err := m.Get("spring-sale"); err != nil || updated.URL != "https://mutated.com" {
+		t.Errorf("want updated URL, got %+v, %v", updated, err)
+	}
+	if err := m.Update(&URL{ID: 42}); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("want ErrRecordNotFound, got %v", err)
+	}
+
+	// Delete.
+	if err := m.Delete("spring-sale"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete("spring-sale"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("want ErrRecordNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStoreInsertBatch(t *testing.T) {
+	m := NewMemoryStore()
+	us := []*URL{
+		{URL: "https://google.com", ShortPath: "aaaa"},
+		{URL: "https://github.com", ShortPath: "aaaa"},
+		{URL: "https://go.dev", ShortPath: "bbbb"},
+	}
+	errs := m.InsertBatch(us)
+	if len(errs) != 3 || errs[0] != nil || !errors.Is(errs[1], ErrDuplicateShortUrl) || errs[2] != nil {
+		t.Fatalf("want the duplicate failed alone, got %v", errs)
+	}
+	if got, err := m.Get("bbbb"); err != nil || got.ID != us[2].ID {
+		t.Errorf("want the URL after the duplicate inserted, got %+v, %v", got, err)
+	}
+}
+
+func TestMemoryClickStore(t *testing.T) {
+	m := NewMemoryClickStore()
+	day1 := time.Date(2022, time.April, 3, 23, 0, 0, 0, time.UTC)
+	day2 := time.Date(2022, time.April, 4, 1, 0, 0, 0, time.UTC)
+	err := m.InsertBatch([]*Click{
+		{URLID: 1, ClickedAt: day1},
+		{URLID: 1, ClickedAt: day2, Referrer: "https://github.com/"},
+		{URLID: 1, ClickedAt: day2},
+		{URLID: 2, ClickedAt: day2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m.Stats(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 3 {
+		t.Errorf("want 3 clicks, got %d", stats.Total)
+	}
+	wantDaily := []DailyClicks{{"2022-04-03", 1}, {"2022-04-04", 2}}
+	if len(stats.Daily) != len(wantDaily) || stats.Daily[0] != wantDaily[0] || stats.Daily[1] != wantDaily[1] {
+		t.Errorf("want daily clicks %v, got %v", wantDaily, stats.Daily)
+	}
+	wantReferrers := []ReferrerClicks{{"", 2}, {"https://github.com/", 1}}
+	if len(stats.Referrers) != len(wantReferrers) || stats.Referrers[0] != wantReferrers[0] || stats.Referrers[1] != wantReferrers[1] {
+		t.Errorf("want referrer clicks %v, got %v", wantReferrers, stats.Referrers)
+	}
+
+	empty, err := m.Stats(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Total != 0 || len(empty.Daily) != 0 || len(empty.Referrers) != 0 {
+		t.Errorf("want no clicks, got %+v", empty)
+	}
+}
+
+func TestMemoryAPIKeys(t *testing.T) {
+	keys := NewMemoryStore().APIKeys()
+
+	k, plaintext, err := NewAPIKey("ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Insert(k); err != nil {
+		t.Fatal(err)
+	}
+	if k.ID != 1 {
+		t.Errorf("want id 1, got %d", k.ID)
+	}
+	if err := keys.Insert(&APIKey{Name: "copy", Hash: k.Hash}); !errors.Is(err, ErrDuplicateAPIKey) {
+		t.Errorf("want ErrDuplicateAPIKey, got %v", err)
+	}
+
+	got, err := keys.GetByHash(HashAPIKey(plaintext))
+	if err != nil || got.ID != k.ID || got.Name != "ci" {
+		t.Errorf("want %+v, got %+v, %v", k, got, err)
+	}
+	if _, err := keys.GetByHash(HashAPIKey("not-a-key")); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("want ErrRecordNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStoreDeleteExpired(t *testing.T) {
+	m := NewMemoryStore()
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	urls := []*URL{
+		{URL: "https://a.com", ShortPath: "aaaa", ExpireAt: now.Add(-3 * time.Hour)},
+		{URL: "https://b.com", ShortPath: "bbbb", ExpireAt: now.Add(-2 * time.Hour)},
+		{URL: "https://c.com", ShortPath: "cccc", ExpireAt: now.Add(-2 * time.Hour)},
+		{URL: "https://d.com", ShortPath: "dddd", ExpireAt: now.Add(time.Hour)},
+		{URL: "https://e.com", ShortPath: "eeee"}, // never expires
+	}
+	for _, u := range urls {
+		if err := m.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// At most limit URLs are deleted.
+	n, err := m.DeleteExpired(now.Add(-time.Hour), 2)
+	if err != nil || n != 2 {
+		t.Errorf("want 2 deleted, got %d, %v", n, err)
+	}
+	n, err = m.DeleteExpired(now.Add(-time.Hour), 2)
+	if err != nil || n != 1 {
+		t.Errorf("want 1 deleted, got %d, %v", n, err)
+	}
+
+	// Unexpired and never expiring URLs are kept.
+	for _, p := range []string{"aaaa", "bbbb", "cccc"} {
+		if _, err := m.Get(p); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("want %q deleted, got %v", p, err)
+		}
+	}
+	for _, p := range []string{"dddd", "eeee"} {
+		if _, err := m.Get(p); err != nil {
+			t.Errorf("want %q kept, got %v", p, err)
+		}
+	}
+}
+
+func TestMemoryStoreReclaimExpired(t *testing.T) {
+	m := NewMemoryStore()
+	expired := &URL{URL: "https://a.com", ShortPath: "aaaa", ExpireAt: time.Now().Add(-time.Hour)}
+	if err := m.Insert(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	// The short path of the expired URL is reclaimed with a new id.
+	u := &URL{URL: "https://b.com", ShortPath: "aaaa", ExpireAt: time.Now().Add(time.Hour)}
+	if err := m.Insert(u); err != nil {
+		t.Fatalf("want expired short path reclaimed, got %v", err)
+	}
+	if u.ID == expired.ID {
+		t.Errorf("want new id, got the id %d of the expired URL", u.ID)
+	}
+	if got, err := m.Get("aaaa"); err != nil || got.URL != "https://b.com" {
+		t.Errorf("want reclaiming URL, got %+v, %v", got, err)
+	}
+
+	// The short path of an unexpired URL is not reclaimed.
+	err := m.Insert(&URL{URL: "https://c.com", ShortPath: "aaaa", ExpireAt: time.Now().Add(time.Hour)})
+	if !errors.Is(err, ErrDuplicateShortUrl) {
+		t.Errorf("want ErrDuplicateShortUrl, got %v", err)
+	}
+}
+
+func TestMemoryStoreReclaimExpiredPersistFailure(t *testing.T) {
+	m := NewMemoryStore()
+	expired := &URL{URL: "https://a.com", ShortPath: "aaaa", ExpireAt: time.Now().Add(-time.Hour)}
+	if err := m.Insert(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	// The expired URL is kept if the reclaiming URL fails to be persisted.
+	var changes []change
+	errPersist := errors.New("disk full")
+	m.persist = func(c change) error {
+		changes = append(changes, c)
+		return errPersist
+	}
+	err := m.Insert(&URL{URL: "https://b.com", ShortPath: "aaaa", ExpireAt: time.Now().Add(time.Hour)})
+	if !errors.Is(err, errPersist) {
+		t.Fatalf("want the persist error, got %v", err)
+	}
+	if len(changes) != 1 || changes[0].Op != opPut {
+		t.Errorf("want the reclaim persisted in a single put, got %+v", changes)
+	}
+	if got, err := m.Get("aaaa"); err != nil || got.ID != expired.ID {
+		t.Errorf("want the expired URL kept, got %+v, %v", got, err)
+	}
+}
+
+func TestMemoryStoreDropClicks(t *testing.T) {
+	m := NewMemoryStore()
+	now := time.Now()
+	urls := []*URL{
+		{URL: "https://a.com", ShortPath: "aaaa"},
+		{URL: "https://b.com", ShortPath: "bbbb", ExpireAt: now.Add(-2 * time.Hour)},
+		{URL: "https://c.com", ShortPath: "cccc", ExpireAt: now.Add(-time.Minute)},
+		{URL: "https://d.com", ShortPath: "dddd"},
+	}
+	for _, u := range urls {
+		if err := m.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var clicks []*Click
+	for _, u := range urls {
+		clicks = append(clicks, &Click{URLID: u.ID, ClickedAt: now})
+	}
+	if err := m.Clicks().InsertBatch(clicks); err != nil {
+		t.Fatal(err)
+	}
+
+	// The clicks are dropped when their URL is deleted, purged or reclaimed.
+	if err := m.Delete("aaaa"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.DeleteExpired(now.Add(-time.Hour), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Insert(&URL{URL: "https://e.com", ShortPath: "cccc"}); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int64{0, 0, 0, 1} {
+		stats, err := m.Clicks().Stats(urls[i].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Total != want {
+			t.Errorf("want %d clicks of %q, got %d", want, urls[i].ShortPath, stats.Total)
+		}
+	}
+}
+
+func TestMemoryStoreNextID(t *testing.T) {
+	m := NewMemoryStore()
+	id, err := m.NextID()
+	if err != nil || id != 1 {
+		t.Fatalf("want id 1, got %d, %v", id, err)
+	}
+
+	// A URL is inserted with the reserved id, and the reserved id is not reused.
+	u := &URL{ID: id, URL: "https://a.com", ShortPath: "1"}
+	if err := m.Insert(u); err != nil || u.ID != 1 {
+		t.Fatalf("want inserted with id 1, got %d, %v", u.ID, err)
+	}
+	u = &URL{URL: "https://b.com", ShortPath: "bbbb"}
+	if err := m.Insert(u); err != nil || u.ID != 2 {
+		t.Errorf("want inserted with id 2, got %d, %v", u.ID, err)
+	}
+	if id, _ := m.NextID(); id != 3 {
+		t.Errorf("want id 3, got %d", id)
+	}
+}
+
+func TestMemoryStoreUseClick(t *testing.T) {
+	m := NewMemoryStore()
+	limited := &URL{URL: "https://a.com", ShortPath: "aaaa", MaxClicks: 3}
+	unlimited := &URL{URL: "https://b.com", ShortPath: "bbbb"}
+	for _, u := range []*URL{limited, unlimited} {
+		if err := m.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Concurrent clicks never exceed MaxClicks.
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- m.UseClick(limited.ID)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var used int
+	for err := range errs {
+		switch {
+		case err == nil:
+			used++
+		case !errors.Is(err, ErrClickLimitReached):
+			t.Errorf("want ErrClickLimitReached, got %v", err)
+		}
+	}
+	if used != 3 {
+		t.Errorf("want 3 counted clicks, got %d", used)
+	}
+
+	// Update does not reset the click count.
+	if err := m.Update(&URL{ID: limited.ID, URL: "https://c.com", ShortPath: "aaaa", MaxClicks: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.Get("aaaa"); got.ClickCount != 3 {
+		t.Errorf("want click count 3 after update, got %d", got.ClickCount)
+	}
+	if err := m.UseClick(limited.ID); !errors.Is(err, ErrClickLimitReached) {
+		t.Errorf("want ErrClickLimitReached after update, got %v", err)
+	}
+
+	// The clicks of a URL without MaxClicks are unlimited.
+	for i := 0; i < 5; i++ {
+		if err := m.UseClick(unlimited.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.UseClick(42); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("want ErrRecordNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package mock
+
+import (
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// Plaintext API keys of the mocked API keys.
+const (
+	APIKey1 = "mock-api-key-1"
+	APIKey2 = "mock-api-key-2"
+)
+
+// APIKeyModel mocks the data.APIKeyModel.
+type APIKeyModel struct{}
+
+// mockAPIKeys are the mocked data.APIKey instances keyed by their plaintext keys.
+var mockAPIKeys = map[string]data.APIKey{
+	APIKey1: {ID: 1, Name: "owner"},
+	APIKey2: {ID: 2, Name: "other"},
+}
+
+// Insert mocks the data.APIKeyModel.Insert method.
+func (m *APIKeyModel) Insert(k *data.APIKey) error {
+	return nil
+}
+
+// GetByHash mocks the data.APIKeyModel.GetByHash method.
+func (m *APIKeyModel) GetByHash(hash []byte) (*data.APIKey, error) {
+	for plaintext, k := range mockAPIKeys {
+		if string(data.HashAPIKey(plaintext)) == string(hash) {
+			k.Hash = hash
+			return &k, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
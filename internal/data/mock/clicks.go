@@ -0,0 +1,53 @@
+package mock
+
+import (
+	"sync"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// ClickModel mocks the data.ClickModel.
+type ClickModel struct {
+	mu     sync.Mutex
+	Clicks []data.Click // inserted clicks
+}
+
+// Insert mocks the data.ClickModel.Insert method.
+func (m *ClickModel) Insert(c *data.Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Clicks = append(m.Clicks, *c)
+	return nil
+}
+
+// InsertBatch mocks the data.ClickModel.InsertBatch method.
+func (m *ClickModel) InsertBatch(clicks []*data.Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range clicks {
+		m.Clicks = append(m.Clicks, *c)
+	}
+	return nil
+}
+
+// Stats mocks the data.ClickModel.Stats method.
+// Only the URL with id 1 has clicks.
+func (m *ClickModel) Stats(urlID int64) (*data.ClickStats, error) {
+	stats := &data.ClickStats{
+		Daily:     []data.DailyClicks{},
+		Referrers: []data.ReferrerClicks{},
+	}
+	if urlID == 1 {
+		stats.Total = 3
+		stats.Daily = []data.DailyClicks{{Date: "2022-04-03", Clicks: 1}, {Date: "2022-04-04", Clicks: 2}}
+		stats.Referrers = []data.ReferrerClicks{{Referrer: "", Clicks: 2}, {Referrer: "https://github.com/", Clicks: 1}}
+	}
+	return stats, nil
+}
+
+// Len returns the number of inserted clicks.
+func (m *ClickModel) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.Clicks)
+}
@@ -2,20 +2,31 @@
 package mock
 
 import (
+	"sync"
 	"time"
 
 	"github.com/Kerseee/urlshortener/internal/data"
 )
 
+// Password is the password protecting the mocked URLs "Pr0tect3" and "Fl4gPr0t".
+const Password = "open-sesame"
+
+// passwordHash is the hash of Password.
+const passwordHash = "$2a$04$PxXU/2o5AW16xtT2/pAlw.S4zgmvbytZkuhdR6Yy52SN/ueRtc/16"
+
 // URLModel mocks the data.URLModel.
-type URLModel struct{}
+type URLModel struct {
+	mu     sync.Mutex
+	nextID int64           // the last id returned by NextID
+	clicks map[int64]int64 // id -> clicks counted by UseClick
+}
 
 // mockURL is a mocked data.URL instance.
 var mockURLs = map[string]data.URL{
 	"BQRvJsg-": {
 		ID:        1,
 		URL:       "https://google.com",
-		ExpireAt:  time.Date(2022, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ExpireAt:  time.Date(2032, time.December, 22, 12, 0, 0, 0, time.UTC),
 		ShortPath: "BQRvJsg-",
 	},
 	"FGeTGg6M": {
@@ -27,33 +38,96 @@ var mockURLs = map[string]data.URL{
 	"zXWCjacZ": {
 		ID:        3,
 		URL:       "https://not-netflix.com",
-		ExpireAt:  time.Date(2024, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ExpireAt:  time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
 		ShortPath: "zXWCjacZ",
 	},
 	"zXWCjacZn": {
 		ID:        4,
 		URL:       "https://not-netflix.com/1",
-		ExpireAt:  time.Date(2024, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ExpireAt:  time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
 		ShortPath: "zXWCjacZn",
 	},
 	"zXWCjacZns": {
 		ID:        5,
 		URL:       "https://not-netflix.com/2",
-		ExpireAt:  time.Date(2024, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ExpireAt:  time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
 		ShortPath: "zXWCjacZns",
 	},
 	"zXWCjacZnsJ": {
 		ID:        6,
 		URL:       "https://not-netflix.com/3",
-		ExpireAt:  time.Date(2024, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ExpireAt:  time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
 		ShortPath: "zXWCjacZnsJ",
 	},
 	"zXWCjacZnsJ4": {
 		ID:        7,
 		URL:       "https://not-netflix.com/4",
-		ExpireAt:  time.Date(2024, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ExpireAt:  time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
 		ShortPath: "zXWCjacZnsJ4",
 	},
+	"0wnedByK": {
+		ID:        8,
+		URL:       "https://github.com",
+		ExpireAt:  time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ShortPath: "0wnedByK",
+		OwnerID:   1,
+	},
+	"PermRedi": {
+		ID:           9,
+		URL:          "https://api.github.com",
+		ExpireAt:     time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ShortPath:    "PermRedi",
+		RedirectCode: 308,
+	},
+	"N3verExp": {
+		ID:        10,
+		URL:       "https://go.dev",
+		ShortPath: "N3verExp",
+	},
+	"Pr0tect3": {
+		ID:           11,
+		URL:          "https://pkg.go.dev",
+		ExpireAt:     time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ShortPath:    "Pr0tect3",
+		PasswordHash: passwordHash,
+	},
+	"0neTime1": {
+		ID:        12,
+		URL:       "https://go.dev/doc",
+		ExpireAt:  time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ShortPath: "0neTime1",
+		MaxClicks: 1,
+	},
+	"F1agged1": {
+		ID:        14,
+		URL:       "https://sketchy.example",
+		ExpireAt:  time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ShortPath: "F1agged1",
+		Flagged:   true,
+	},
+	"S00nLive": {
+		ID:         13,
+		URL:        "https://go.dev/blog",
+		ExpireAt:   time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ActiveFrom: time.Date(2033, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ShortPath:  "S00nLive",
+	},
+	"Fl4gPr0t": {
+		ID:           16,
+		URL:          "https://hidden.example",
+		ExpireAt:     time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ShortPath:    "Fl4gPr0t",
+		PasswordHash: passwordHash,
+		Flagged:      true,
+	},
+	"Prev1ew1": {
+		ID:            15,
+		URL:           "https://go.dev/play",
+		ExpireAt:      time.Date(2034, time.December, 22, 12, 0, 0, 0, time.UTC),
+		ShortPath:     "Prev1ew1",
+		AlwaysPreview: true,
+		CreatedAt:     time.Date(2024, time.March, 1, 9, 30, 0, 0, time.UTC),
+	},
 }
 
 // Get mocks the data.URLModel.Get method.
@@ -67,12 +141,66 @@ func (m *URLModel) Get(s string) (*data.URL, error) {
 
 // Insert mocks the data.URLModel.Insert method.
 func (m *URLModel) Insert(u *data.URL) error {
-	if _, ok := mockURLs[u.ShortPath]; ok {
+	if record, ok := mockURLs[u.ShortPath]; ok && !record.Expired(time.Now()) {
 		return data.ErrDuplicateShortUrl
 	}
 	return nil
 }
 
+// InsertBatch mocks the data.URLModel.InsertBatch method.
+func (m *URLModel) InsertBatch(us []*data.URL) []error {
+	errs := make([]error, len(us))
+	for i, u := range us {
+		errs[i] = m.Insert(u)
+	}
+	return errs
+}
+
+// Update mocks the data.URLModel.Update method.
 func (m *URLModel) Update(u *data.URL) error {
 	return nil
 }
+
+// Delete mocks the data.URLModel.Delete method.
+func (m *URLModel) Delete(s string) error {
+	if _, ok := mockURLs[s]; !ok {
+		return data.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteExpired mocks the data.URLModel.DeleteExpired method.
+func (m *URLModel) DeleteExpired(before time.Time, limit int) (int64, error) {
+	return 0, nil
+}
+
+// NextID mocks the data.URLModel.NextID method, returning the ids after the ids of the mocked URLs.
+func (m *URLModel) NextID() (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nextID == 0 {
+		m.nextID = int64(len(mockURLs))
+	}
+	m.nextID++
+	return m.nextID, nil
+}
+
+// UseClick mocks the data.URLModel.UseClick method, counting the clicks per URLModel.
+func (m *URLModel) UseClick(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range mockURLs {
+		if u.ID != id {
+			continue
+		}
+		if m.clicks == nil {
+			m.clicks = make(map[int64]int64)
+		}
+		if u.MaxClicks > 0 && u.ClickCount+m.clicks[id] >= u.MaxClicks {
+			return data.ErrClickLimitReached
+		}
+		m.clicks[id]++
+		return nil
+	}
+	return data.ErrRecordNotFound
+}
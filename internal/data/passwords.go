@@ -0,0 +1,29 @@
+package data
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordCost is the bcrypt cost of the password hashes.
+const passwordCost = 12
+
+// HashPassword returns the salted bcrypt hash of the plaintext password.
+// It returns an error if plaintext is longer than 72 bytes, which bcrypt cannot hash.
+func HashPassword(plaintext string) (string, error) {
+	return hashPassword(plaintext, passwordCost)
+}
+
+// hashPassword returns the bcrypt hash of plaintext with cost.
+func hashPassword(plaintext string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether the plaintext password matches the password hash returned by HashPassword.
+// It reports false if hash is malformed.
+func CheckPassword(hash, plaintext string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plaintext)) == nil
+}
@@ -0,0 +1,47 @@
+package data
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("open-sesame", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := hashPassword("open-sesame", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == other {
+		t.Errorf("want salted hashes, got %q twice", hash)
+	}
+
+	tests := []struct {
+		name      string
+		hash      string
+		plaintext string
+		want      bool
+	}{
+		{"correct password", hash, "open-sesame", true},
+		{"wrong password", hash, "open-sesame!", false},
+		{"empty password", hash, "", false},
+		{"empty hash", "", "open-sesame", false},
+		{"malformed hash", "pbkdf2_sha256$1000$c2FsdA$a2V5", "open-sesame", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := CheckPassword(test.hash, test.plaintext); got != test.want {
+				t.Errorf("want %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestHashPasswordTooLong(t *testing.T) {
+	if _, err := hashPassword(string(make([]byte, 73)), bcrypt.MinCost); err == nil {
+		t.Error("want error hashing a password longer than 72 bytes, got nil")
+	}
+}
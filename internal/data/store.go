@@ -0,0 +1,74 @@
+package data
+
+import "time"
+
+// Store is a storage backend of the shortened URLs.
+//
+// URLModel stores the URLs in PostgreSQL, MemoryStore keeps them in memory,
+// and FileStore keeps them in memory and persists them into an append-only file.
+type Store interface {
+	// Get returns the URL having the short path s, or ErrRecordNotFound.
+	Get(s string) (*URL, error)
+
+	// Insert inserts u and populates u.ID and u.CreatedAt. If u.ID is not 0, it must be taken from NextID.
+	// It returns ErrDuplicateShortUrl if u.ShortPath is already used by an unexpired URL.
+	// An expired URL using u.ShortPath is deleted with its clicks, and u gets a new ID.
+	Insert(u *URL) error
+
+	// InsertBatch inserts the URLs in us as Insert does, and returns the error of inserting each URL in the same order.
+	// The failure of a URL does not fail the others.
+	InsertBatch(us []*URL) []error
+
+	// Update updates the URL having the id u.ID, or returns ErrRecordNotFound.
+	Update(u *URL) error
+
+	// Delete deletes the URL having the short path s, or returns ErrRecordNotFound.
+	Delete(s string) error
+
+	// DeleteExpired deletes at most limit URLs expired before the time before,
+	// and returns the number of deleted URLs.
+	DeleteExpired(before time.Time, limit int) (int64, error)
+
+	// NextID reserves and returns the next id of the URLs, which is never returned again.
+	NextID() (int64, error)
+
+	// UseClick atomically counts a click of the URL having the id into its ClickCount,
+	// unless the URL has a MaxClicks and its ClickCount has reached MaxClicks.
+	// It returns ErrClickLimitReached if the limit has been reached, or ErrRecordNotFound.
+	UseClick(id int64) error
+}
+
+// ClickStore is a storage backend of the clicks.
+//
+// ClickModel stores the clicks in PostgreSQL and MemoryClickStore keeps them in memory.
+type ClickStore interface {
+	// InsertBatch inserts clicks.
+	InsertBatch(clicks []*Click) error
+
+	// Stats summarizes the clicks of the URL having the id urlID.
+	Stats(urlID int64) (*ClickStats, error)
+}
+
+// APIKeyStore is a storage backend of the API keys.
+//
+// APIKeyModel stores the API keys in PostgreSQL, and MemoryStore.APIKeys keeps them in a MemoryStore or a FileStore.
+type APIKeyStore interface {
+	// Insert inserts k and populates k.ID.
+	// It returns ErrDuplicateAPIKey if k.Hash already exists.
+	Insert(k *APIKey) error
+
+	// GetByHash returns the API key having the hash, or ErrRecordNotFound.
+	GetByHash(hash []byte) (*APIKey, error)
+}
+
+// Check that the storage backends implement the interfaces.
+var (
+	_ Store      = (*URLModel)(nil)
+	_ Store      = (*MemoryStore)(nil)
+	_ Store      = (*FileStore)(nil)
+	_ ClickStore = (*ClickModel)(nil)
+	_ ClickStore = (*MemoryClickStore)(nil)
+
+	_ APIKeyStore = (*APIKeyModel)(nil)
+	_ APIKeyStore = memoryAPIKeys{}
+)
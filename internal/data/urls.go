@@ -1,4 +1,4 @@
-// Package data provides the url model for executing query from and to the database.
+// Package data provides the models and the storage backends of the shortened URLs and their clicks.
 package data
 
 import (
@@ -14,10 +14,13 @@ import (
 var (
 	ErrRecordNotFound    = errors.New("record is not found")
 	ErrDuplicateShortUrl = errors.New("duplicate unexpired shortened URL")
+	ErrClickLimitReached = errors.New("click limit of shortened URL is reached")
 )
 
 const (
 	errMsgViolateUniquePQ string = "pq: duplicate key value violates unique constraint"
+
+	insertBatchSize = 100 // maximum number of URLs inserted in a transaction by InsertBatch
 )
 
 // URLModel is a wrapper of a db connection pool.
@@ -28,17 +31,60 @@ type URLModel struct {
 
 // URL holds an entry of the table "urls" in the database.
 type URL struct {
-	ID        int64
-	URL       string
-	ExpireAt  time.Time
-	ShortPath string
+	ID         int64
+	URL        string
+	ExpireAt   time.Time // zero if the URL never expires, which is NULL in the table
+	ActiveFrom time.Time // start of the redirects, zero if the URL is active once created, which is NULL in the table
+	ShortPath  string
+	OwnerID    int64 // id of the API key creating the URL, 0 if the URL has no owner
+
+	// RedirectCode is the HTTP status code redirecting to URL, 0 for the default of the application.
+	RedirectCode int
+
+	// PasswordHash is the hash of the password protecting the redirect by HashPassword,
+	// empty if the URL is not protected, which is NULL in the table.
+	PasswordHash string
+
+	// Flagged reports whether URL is suspected to be harmful by a scanner,
+	// and its visitors should be warned before the redirect.
+	Flagged bool
+
+	// AlwaysPreview reports whether the visitors are always shown the preview page of URL before the redirect.
+	AlwaysPreview bool
+
+	// MaxClicks is the maximum number of redirects of URL, 0 if unlimited, which is NULL in the table.
+	MaxClicks int64
+
+	// ClickCount is the number of redirects counted by UseClick. It is only counted for the URLs with MaxClicks,
+	// and is never changed by Insert or Update.
+	ClickCount int64
+
+	// CreatedAt is the time when URL is inserted. It is set by Insert and never changed by Update.
+	// It is zero if unknown, which is NULL in the table for the URLs inserted before the column was added.
+	CreatedAt time.Time
+}
+
+// Protected reports whether the redirect of u is protected by a password.
+func (u *URL) Protected() bool {
+	return u.PasswordHash != ""
+}
+
+// Expired reports whether u has expired at now. A URL with zero ExpireAt never expires.
+func (u *URL) Expired(now time.Time) bool {
+	return !u.ExpireAt.IsZero() && u.ExpireAt.Before(now)
+}
+
+// Pending reports whether u is not active yet at now. A URL with zero ActiveFrom is always active.
+func (u *URL) Pending(now time.Time) bool {
+	return !u.ActiveFrom.IsZero() && now.Before(u.ActiveFrom)
 }
 
 // Get return a URL instance based on given shortPath.
 func (m *URLModel) Get(s string) (*URL, error) {
 	// Prepare the query and arguments
 	query := `
-		SELECT id, url, short_url, expire_at
+		SELECT id, url, short_url, expire_at, active_from, owner_id, redirect_code, password_hash, flagged,
+			always_preview, max_clicks, click_count, created_at
 		FROM urls
 		WHERE short_url = $1`
 	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
@@ -46,11 +92,24 @@ func (m *URLModel) Get(s string) (*URL, error) {
 
 	// Execute the query
 	var u URL
+	var expireAt, activeFrom, createdAt sql.NullTime
+	var ownerID sql.NullInt64
+	var passwordHash sql.NullString
+	var maxClicks sql.NullInt64
 	err := m.DB.QueryRowContext(ctx, query, s).Scan(
 		&u.ID,
 		&u.URL,
 		&u.ShortPath,
-		&u.ExpireAt,
+		&expireAt,
+		&activeFrom,
+		&ownerID,
+		&u.RedirectCode,
+		&passwordHash,
+		&u.Flagged,
+		&u.AlwaysPreview,
+		&maxClicks,
+		&u.ClickCount,
+		&createdAt,
 	)
 	if err != nil {
 		switch {
@@ -60,22 +119,125 @@ func (m *URLModel) Get(s string) (*URL, error) {
 			return nil, err
 		}
 	}
+	u.ExpireAt = expireAt.Time
+	u.ActiveFrom = activeFrom.Time
+	u.OwnerID = ownerID.Int64
+	u.PasswordHash = passwordHash.String
+	u.MaxClicks = maxClicks.Int64
+	u.CreatedAt = createdAt.Time
 	return &u, nil
 }
 
 // Insert inserts a URL into urls table in the database.
-func (m *URLModel) Insert(u *URL) error {
-	// Prepare the query and arguments.
-	query := `
-		INSERT INTO urls(url, short_url, expire_at)
-		VALUES ($1, $2, $3)
-		RETURNING id`
-	args := []interface{}{u.URL, u.ShortPath, u.ExpireAt.UTC()}
+// The id of the URL is taken from the sequence of the table unless u.ID is reserved by NextID.
+//
+// An expired URL having the same short path is deleted with its clicks in the same transaction,
+// so that the short path is reclaimed for u.
+func (m *URLModel) Insert(u *URL) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	// Begin a transaction, so that the expired URL is only deleted if u is inserted.
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = insertURL(ctx, tx, u); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertBatch inserts the URLs in us into urls table in the database as Insert does,
+// and returns the error of inserting each URL in the same order.
+//
+// The URLs are inserted in chunks of insertBatchSize URLs, one transaction per chunk,
+// and every URL is inserted under a savepoint, so that a failed URL is rolled back without failing the others.
+// If a chunk fails to commit, all the URLs in the chunk fail with the error.
+func (m *URLModel) InsertBatch(us []*URL) []error {
+	errs := make([]error, len(us))
+	for start := 0; start < len(us); start += insertBatchSize {
+		end := start + insertBatchSize
+		if end > len(us) {
+			end = len(us)
+		}
+		chunk := us[start:end]
+
+		// Keep the ids of the chunk, which are populated even if the chunk fails to commit.
+		ids := make([]int64, len(chunk))
+		for i, u := range chunk {
+			ids[i] = u.ID
+		}
+		if err := m.insertChunk(chunk, errs[start:end]); err != nil {
+			for i, u := range chunk {
+				u.ID = ids[i]
+				errs[start+i] = err
+			}
+		}
+	}
+	return errs
+}
+
+// insertChunk inserts the URLs in us in a transaction, and sets the error of inserting each URL into errs.
+// It returns the error failing the whole transaction.
+func (m *URLModel) insertChunk(us []*URL, errs []error) (err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
 	defer cancel()
 
-	// Execute the query.
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&u.ID)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i, u := range us {
+		if _, err = tx.ExecContext(ctx, "SAVEPOINT insert_url"); err != nil {
+			return err
+		}
+		if errs[i] = insertURL(ctx, tx, u); errs[i] != nil {
+			if _, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT insert_url"); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT insert_url"); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// insertURL inserts u in tx, deleting the expired URL having the same short path first.
+func insertURL(ctx context.Context, tx *sql.Tx, u *URL) error {
+	// Prepare the queries and arguments.
+	reclaimQuery := `
+		DELETE FROM urls
+		WHERE short_url = $1 AND expire_at < now()`
+	query := `
+		INSERT INTO urls(id, url, short_url, expire_at, active_from, owner_id, redirect_code, password_hash, flagged,
+			always_preview, max_clicks)
+		VALUES (COALESCE($1, nextval(pg_get_serial_sequence('urls', 'id'))), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at`
+	args := []interface{}{nullInt64(u.ID), u.URL, u.ShortPath, nullTime(u.ExpireAt), nullTime(u.ActiveFrom), nullInt64(u.OwnerID),
+		u.RedirectCode, nullString(u.PasswordHash), u.Flagged, u.AlwaysPreview, nullInt64(u.MaxClicks)}
+
+	// Execute the queries.
+	// A concurrent Insert of the same short path waits for the deleted row,
+	// and fails with the unique constraint violation after this transaction commits.
+	if _, err := tx.ExecContext(ctx, reclaimQuery, u.ShortPath); err != nil {
+		return err
+	}
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&u.ID, &u.CreatedAt)
 	if err != nil {
 		switch {
 		case strings.Contains(err.Error(), errMsgViolateUniquePQ):
@@ -84,7 +246,6 @@ func (m *URLModel) Insert(u *URL) error {
 			return err
 		}
 	}
-
 	return nil
 }
 
@@ -92,13 +253,139 @@ func (m *URLModel) Insert(u *URL) error {
 func (m *URLModel) Update(u *URL) error {
 	// Prepare the query
 	query := `
-		UPDATE urls SET url = $1, short_url = $2, expire_at = $3
-		WHERE id = $4`
-	args := []interface{}{u.URL, u.ShortPath, u.ExpireAt, u.ID}
+		UPDATE urls
+		SET url = $1, short_url = $2, expire_at = $3, active_from = $4, owner_id = $5, redirect_code = $6,
+			password_hash = $7, flagged = $8, always_preview = $9, max_clicks = $10
+		WHERE id = $11`
+	args := []interface{}{u.URL, u.ShortPath, nullTime(u.ExpireAt), nullTime(u.ActiveFrom), nullInt64(u.OwnerID), u.RedirectCode,
+		nullString(u.PasswordHash), u.Flagged, u.AlwaysPreview, nullInt64(u.MaxClicks), u.ID}
 	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
 	defer cancel()
 
 	// Execute the query
-	_, err := m.DB.ExecContext(ctx, query, args...)
-	return err
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete deletes the URL having the shortPath s from the urls table in the database.
+func (m *URLModel) Delete(s string) error {
+	// Prepare the query
+	query := `
+		DELETE FROM urls
+		WHERE short_url = $1`
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	// Execute the query
+	result, err := m.DB.ExecContext(ctx, query, s)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteExpired deletes at most limit URLs expired before the time before from the urls table in the database,
+// and returns the number of deleted URLs. The clicks of the deleted URLs are deleted as well.
+func (m *URLModel) DeleteExpired(before time.Time, limit int) (int64, error) {
+	// Prepare the query
+	query := `
+		DELETE FROM urls
+		WHERE id IN (
+			SELECT id FROM urls
+			WHERE expire_at < $1
+			ORDER BY expire_at
+			LIMIT $2
+		)`
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	// Execute the query
+	result, err := m.DB.ExecContext(ctx, query, before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// NextID reserves and returns the next id from the sequence of the urls table.
+func (m *URLModel) NextID() (int64, error) {
+	// Prepare the query
+	query := `SELECT nextval(pg_get_serial_sequence('urls', 'id'))`
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	// Execute the query
+	var id int64
+	err := m.DB.QueryRowContext(ctx, query).Scan(&id)
+	return id, err
+}
+
+// UseClick counts a click of the URL having the id in the urls table in the database,
+// unless the click count of the URL has reached its max_clicks.
+//
+// The check and the increment are done in a single UPDATE, which locks the row,
+// so that concurrent clicks never exceed max_clicks.
+func (m *URLModel) UseClick(id int64) error {
+	// Prepare the queries
+	query := `
+		UPDATE urls SET click_count = click_count + 1
+		WHERE id = $1 AND (max_clicks IS NULL OR click_count < max_clicks)`
+	existsQuery := `SELECT EXISTS(SELECT 1 FROM urls WHERE id = $1)`
+	ctx, cancel := context.WithTimeout(context.Background(), m.QueryTimeOut)
+	defer cancel()
+
+	// Execute the query
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	// Tell the reached limit from the deleted URL.
+	var exists bool
+	if err := m.DB.QueryRowContext(ctx, existsQuery, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRecordNotFound
+	}
+	return ErrClickLimitReached
+}
+
+// nullInt64 converts v into sql.NullInt64, treating 0 as NULL.
+func nullInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: v, Valid: v != 0}
+}
+
+// nullString converts s into sql.NullString, treating "" as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullTime converts t into sql.NullTime in UTC, treating the zero time as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
 }
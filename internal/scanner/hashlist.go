@@ -0,0 +1,148 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// HashList is a Scanner looking up the SHA-256 hashes of the expressions of the URLs in a local file,
+// so that the file does not reveal the listed URLs. It is safe for concurrent use, and can be reloaded.
+//
+// Each line of the file is the hex-encoded SHA-256 hash of an expression returned by Expressions,
+// optionally followed by a verdict, which is "malicious" if omitted:
+//
+//	# www.evil.com/login
+//	4d5f...e1 malicious
+//	# sketchy.example/
+//	0b9a...7c suspicious
+//
+// Empty lines and lines starting with '#' are ignored.
+type HashList struct {
+	path string
+
+	mu     sync.RWMutex
+	hashes map[[sha256.Size]byte]Verdict
+}
+
+// OpenHashList creates the HashList loaded from the file at path.
+func OpenHashList(path string) (*HashList, error) {
+	l := &HashList{path: path}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload reads the file of l again. The hashes are unchanged if the file cannot be read.
+func (l *HashList) Reload() error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hashes := make(map[[sha256.Size]byte]Verdict)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		b, err := hex.DecodeString(fields[0])
+		if err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("scanner: invalid SHA-256 hash at %s:%d", l.path, n)
+		}
+		var hash [sha256.Size]byte
+		copy(hash[:], b)
+		verdict := Malicious
+		if len(fields) > 1 {
+			if verdict, err = parseVerdict(fields[1]); err != nil {
+				return fmt.Errorf("%w at %s:%d", err, l.path, n)
+			}
+		}
+		hashes[hash] = verdict
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hashes = hashes
+	return nil
+}
+
+// Scan returns the most severe verdict of the expressions of rawURL in l, or Safe if none is listed.
+func (l *HashList) Scan(ctx context.Context, rawURL string) (Verdict, error) {
+	exprs, err := Expressions(rawURL)
+	if err != nil {
+		return Safe, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	verdict := Safe
+	for _, expr := range exprs {
+		if v, ok := l.hashes[sha256.Sum256([]byte(expr))]; ok && v > verdict {
+			verdict = v
+		}
+	}
+	return verdict, nil
+}
+
+// Hash returns the hex-encoded SHA-256 hash of the expression expr, which is listed in the file of a HashList.
+func Hash(expr string) string {
+	hash := sha256.Sum256([]byte(expr))
+	return hex.EncodeToString(hash[:])
+}
+
+// Expressions returns the expressions of rawURL looked up in a HashList, from the most to the least specific:
+// the lowercase host without port followed by the path and the query, by the path,
+// and the host and its parent domains of at least two labels followed by "/".
+//
+// For example, the expressions of "https://www.Evil.com:8443/login?id=1#top" are
+// "www.evil.com/login?id=1", "www.evil.com/login", "www.evil.com/" and "evil.com/".
+func Expressions(rawURL string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return nil, errors.New("scanner: url without host")
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	var exprs []string
+	if u.RawQuery != "" {
+		exprs = append(exprs, host+path+"?"+u.RawQuery)
+	}
+	if path != "/" {
+		exprs = append(exprs, host+path)
+	}
+	exprs = append(exprs, host+"/")
+	if net.ParseIP(host) != nil {
+		return exprs, nil
+	}
+	for {
+		i := strings.IndexByte(host, '.')
+		if i < 0 || !strings.Contains(host[i+1:], ".") {
+			break
+		}
+		host = host[i+1:]
+		exprs = append(exprs, host+"/")
+	}
+	return exprs, nil
+}
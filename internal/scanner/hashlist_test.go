@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpressions(t *testing.T) {
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://www.Evil.com:8443/login?id=1#top", []string{"www.evil.com/login?id=1", "www.evil.com/login", "www.evil.com/", "evil.com/"}},
+		{"http://evil.com", []string{"evil.com/"}},
+		{"http://a.b.evil.co.uk./", []string{"a.b.evil.co.uk/", "b.evil.co.uk/", "evil.co.uk/", "co.uk/"}},
+		{"http://8.8.8.8/x", []string{"8.8.8.8/x", "8.8.8.8/"}},
+	}
+	for _, test := range tests {
+		got, err := Expressions(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: want %q, got %q", test.url, test.want, got)
+		}
+	}
+	if _, err := Expressions("http://"); err == nil {
+		t.Error("want error for url without host, got nil")
+	}
+}
+
+func TestHashList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hashes")
+	content := "# harmful urls\n" +
+		Hash("evil.com/") + "\n" +
+		Hash("sketchy.example/download") + " suspicious\n" +
+		"\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := OpenHashList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url  string
+		want Verdict
+	}{
+		{"https://evil.com", Malicious},
+		{"https://login.evil.com/reset?user=1", Malicious},
+		{"https://sketchy.example/download", Suspicious},
+		{"https://sketchy.example/", Safe},
+		{"https://notevil.com", Safe},
+	}
+	for _, test := range tests {
+		got, err := l.Scan(context.Background(), test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: want %v, got %v", test.url, test.want, got)
+		}
+	}
+
+	// An invalid file is rejected, and the hashes are kept.
+	if err := os.WriteFile(path, []byte("not-a-hash\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reload(); err == nil {
+		t.Error("want error reloading invalid file, got nil")
+	}
+	if got, _ := l.Scan(context.Background(), "https://evil.com"); got != Malicious {
+		t.Errorf("want hashes kept after failed reload, got %v", got)
+	}
+
+	// A valid file is reloaded.
+	if err := os.WriteFile(path, []byte(Hash("phishing.example/")+" malicious\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := l.Scan(context.Background(), "https://evil.com"); got != Safe {
+		t.Errorf("want evil.com safe after reload, got %v", got)
+	}
+	if got, _ := l.Scan(context.Background(), "https://phishing.example/login"); got != Malicious {
+		t.Errorf("want phishing.example malicious after reload, got %v", got)
+	}
+}
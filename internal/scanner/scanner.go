@@ -0,0 +1,78 @@
+// Package scanner provides the scanners checking the URLs to be shortened for phishing and malware.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// A Verdict is the result of scanning a URL.
+type Verdict int
+
+// Verdicts from the least to the most severe.
+const (
+	Safe       Verdict = iota // no threat is found
+	Suspicious                // the URL may be harmful, and its visitors should be warned
+	Malicious                 // the URL is harmful, and should not be shortened
+)
+
+// String returns the name of v.
+func (v Verdict) String() string {
+	switch v {
+	case Safe:
+		return "safe"
+	case Suspicious:
+		return "suspicious"
+	case Malicious:
+		return "malicious"
+	default:
+		return fmt.Sprintf("Verdict(%d)", int(v))
+	}
+}
+
+// parseVerdict parses the name of a Verdict.
+func parseVerdict(s string) (Verdict, error) {
+	switch s {
+	case "safe":
+		return Safe, nil
+	case "suspicious":
+		return Suspicious, nil
+	case "malicious":
+		return Malicious, nil
+	default:
+		return Safe, fmt.Errorf("scanner: unknown verdict %q", s)
+	}
+}
+
+// A Scanner scans the URLs to be shortened.
+type Scanner interface {
+	// Scan returns the verdict of the absolute http or https URL rawURL.
+	// It returns an error if the URL cannot be scanned, e.g. the scanning service is unavailable.
+	Scan(ctx context.Context, rawURL string) (Verdict, error)
+}
+
+// Multi is a Scanner scanning URLs by all its scanners, which returns the most severe verdict.
+// A Malicious verdict is returned even if other scanners fail.
+// Otherwise, the errors of the scanners are joined and returned with the most severe verdict of the others.
+type Multi []Scanner
+
+// Scan scans rawURL by all the scanners in m.
+func (m Multi) Scan(ctx context.Context, rawURL string) (Verdict, error) {
+	verdict := Safe
+	var errs []error
+	for _, s := range m {
+		v, err := s.Scan(ctx, rawURL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if v > verdict {
+			verdict = v
+		}
+	}
+	if verdict == Malicious {
+		return verdict, nil
+	}
+	return verdict, errors.Join(errs...)
+}
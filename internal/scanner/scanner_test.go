@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stub is a Scanner returning verdict and err.
+type stub struct {
+	verdict Verdict
+	err     error
+}
+
+func (s stub) Scan(ctx context.Context, rawURL string) (Verdict, error) {
+	return s.verdict, s.err
+}
+
+func TestMulti(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+	tests := []struct {
+		name        string
+		m           Multi
+		wantVerdict Verdict
+		wantErr     error
+	}{
+		{"no scanner", Multi{}, Safe, nil},
+		{"all safe", Multi{stub{Safe, nil}, stub{Safe, nil}}, Safe, nil},
+		{"most severe verdict", Multi{stub{Suspicious, nil}, stub{Malicious, nil}, stub{Safe, nil}}, Malicious, nil},
+		{"malicious despite error", Multi{stub{Safe, errUnavailable}, stub{Malicious, nil}}, Malicious, nil},
+		{"suspicious with error", Multi{stub{Suspicious, nil}, stub{Safe, errUnavailable}}, Suspicious, errUnavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verdict, err := test.m.Scan(context.Background(), "https://example.com")
+			if verdict != test.wantVerdict {
+				t.Errorf("want verdict %v, got %v", test.wantVerdict, verdict)
+			}
+			if !errors.Is(err, test.wantErr) || (test.wantErr == nil && err != nil) {
+				t.Errorf("want error %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseVerdict(t *testing.T) {
+	for _, v := range []Verdict{Safe, Suspicious, Malicious} {
+		got, err := parseVerdict(v.String())
+		if err != nil || got != v {
+			t.Errorf("want %v, got %v, %v", v, got, err)
+		}
+	}
+	if _, err := parseVerdict("harmless"); err == nil {
+		t.Error("want error for unknown verdict, got nil")
+	}
+}
@@ -0,0 +1,59 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxWebhookResponse is the maximum size of the response body of a webhook.
+const maxWebhookResponse = 1 << 20 // 1MB
+
+// Webhook is a Scanner asking an HTTP service for the verdicts.
+//
+// It POSTs the JSON {"url": "<url>"} to URL, and expects a 2xx response with the JSON
+// {"verdict": "safe"}, where the verdict is one of "safe", "suspicious" and "malicious".
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook creates the Webhook posting to url, whose requests time out after timeout.
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+// Scan asks the webhook for the verdict of rawURL.
+func (w *Webhook) Scan(ctx context.Context, rawURL string) (Verdict, error) {
+	// Send the request.
+	body, err := json.Marshal(map[string]string{"url": rawURL})
+	if err != nil {
+		return Safe, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return Safe, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return Safe, err
+	}
+	defer resp.Body.Close()
+
+	// Read the verdict.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Safe, fmt.Errorf("scanner: webhook responded %s", resp.Status)
+	}
+	var result struct {
+		Verdict string `json:"verdict"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxWebhookResponse)).Decode(&result); err != nil {
+		return Safe, fmt.Errorf("scanner: decoding webhook response: %w", err)
+	}
+	return parseVerdict(result.Verdict)
+}
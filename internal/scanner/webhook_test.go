@@ -0,0 +1,64 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	// The stub scanning service responds by the requested url.
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URL string `json:"url"`
+		}
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.URL {
+		case "https://evil.com":
+			w.Write([]byte(`{"verdict":"malicious"}`))
+		case "https://sketchy.example":
+			w.Write([]byte(`{"verdict":"suspicious"}`))
+		case "https://broken.example":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "https://garbage.example":
+			w.Write([]byte(`<html>`))
+		case "https://unknown.example":
+			w.Write([]byte(`{"verdict":"harmless"}`))
+		case "https://slow.example":
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte(`{"verdict":"safe"}`))
+		default:
+			w.Write([]byte(`{"verdict":"safe"}`))
+		}
+	}))
+	defer stub.Close()
+
+	tests := []struct {
+		url         string
+		wantVerdict Verdict
+		wantErr     bool
+	}{
+		{"https://google.com", Safe, false},
+		{"https://evil.com", Malicious, false},
+		{"https://sketchy.example", Suspicious, false},
+		{"https://broken.example", Safe, true},
+		{"https://garbage.example", Safe, true},
+		{"https://unknown.example", Safe, true},
+		{"https://slow.example", Safe, true},
+	}
+	w := NewWebhook(stub.URL, 100*time.Millisecond)
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			verdict, err := w.Scan(context.Background(), test.url)
+			if verdict != test.wantVerdict || (err != nil) != test.wantErr {
+				t.Errorf("want %v, error %v, got %v, %v", test.wantVerdict, test.wantErr, verdict, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package urlshortener
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// A urlCache is a size-bounded LRU cache in front of a data.Store.
+//
+// A URL is cached for at most ttl and never beyond its ExpireAt if it expires.
+// A short path that is not found is cached for negativeTTL.
+// Entries are invalidated when the short path is inserted, updated or deleted through the cache.
+type urlCache struct {
+	store       data.Store
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	ll    *list.List               // most recently used entries at the front
+	items map[string]*list.Element // short path -> element holding a *cacheEntry
+	gen   uint64                   // incremented on every invalidation
+}
+
+// A cacheEntry is a cached result of data.Store.Get.
+type cacheEntry struct {
+	key      string
+	u        *data.URL // nil if the short path is not found
+	expireAt time.Time
+}
+
+// newURLCache creates a urlCache holding at most size entries in front of store.
+func newURLCache(store data.Store, size int, ttl, negativeTTL time.Duration) *urlCache {
+	return &urlCache{
+		store:       store,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		now:         time.Now,
+		ll:          list.New(),
+		items:       make(map[string]*list.Element),
+	}
+}
+
+// Get returns the URL having the shortPath s from the cache, or from the store in case of a cache miss.
+func (c *urlCache) Get(s string) (*data.URL, error) {
+	c.mu.Lock()
+	if el, ok := c.items[s]; ok {
+		e := el.Value.(*cacheEntry)
+		if c.now().Before(e.expireAt) {
+			c.ll.MoveToFront(el)
+			c.mu.Unlock()
+			if e.u == nil {
+				return nil, data.ErrRecordNotFound
+			}
+			u := *e.u
+			return &u, nil
+		}
+		c.remove(el)
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	u, err := c.store.Get(s)
+	switch {
+	case err == nil:
+		ttl := c.ttl
+		if left := u.ExpireAt.Sub(c.now()); !u.ExpireAt.IsZero() && left < ttl {
+			ttl = left
+		}
+		cached := *u
+		c.add(s, &cached, ttl, gen)
+	case errors.Is(err, data.ErrRecordNotFound):
+		c.add(s, nil, c.negativeTTL, gen)
+	}
+	return u, err
+}
+
+// Insert inserts u into the store and invalidates the cached u.ShortPath.
+func (c *urlCache) Insert(u *data.URL) error {
+	err := c.store.Insert(u)
+	c.invalidate(u.ShortPath)
+	return err
+}
+
+// InsertBatch inserts the URLs in us into the store and invalidates their cached short paths.
+func (c *urlCache) InsertBatch(us []*data.URL) []error {
+	errs := c.store.InsertBatch(us)
+	for _, u := range us {
+		c.invalidate(u.ShortPath)
+	}
+	return errs
+}
+
+// Update updates u in the store and invalidates the cached u.ShortPath.
+func (c *urlCache) Update(u *data.URL) error {
+	err := c.store.Update(u)
+	c.invalidate(u.ShortPath)
+	return err
+}
+
+// Delete deletes the URL having the shortPath s from the store and invalidates the cached s.
+func (c *urlCache) Delete(s string) error {
+	err := c.store.Delete(s)
+	c.invalidate(s)
+	return err
+}
+
+// DeleteExpired deletes at most limit URLs expired before the time before from the store.
+// Nothing is invalidated since expired URLs are never cached.
+func (c *urlCache) DeleteExpired(before time.Time, limit int) (int64, error) {
+	return c.store.DeleteExpired(before, limit)
+}
+
+// NextID reserves and returns the next id of the URLs from the store.
+func (c *urlCache) NextID() (int64, error) {
+	return c.store.NextID()
+}
+
+// UseClick counts a click of the URL having the id in the store.
+// Nothing is invalidated, since the click limit is always checked by the store
+// and the ClickCount of a cached URL only lags behind.
+func (c *urlCache) UseClick(id int64) error {
+	return c.store.UseClick(id)
+}
+
+// add caches u for ttl under key s, evicting the least recently used entry if the cache is full.
+// Nothing is cached if ttl is not positive or the cache is invalidated since gen.
+func (c *urlCache) add(s string, u *data.URL, ttl time.Duration, gen uint64) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	e := &cacheEntry{key: s, u: u, expireAt: c.now().Add(ttl)}
+	if el, ok := c.items[s]; ok {
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[s] = c.ll.PushFront(e)
+	for c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+// invalidate removes the cached s.
+func (c *urlCache) invalidate(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if el, ok := c.items[s]; ok {
+		c.remove(el)
+	}
+}
+
+// remove removes el from the cache. c.mu must be held.
+func (c *urlCache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
+
+// len returns the number of cached entries.
+func (c *urlCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
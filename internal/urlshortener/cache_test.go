@@ -0,0 +1,146 @@
+package urlshortener
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+	"github.com/Kerseee/urlshortener/internal/data/mock"
+)
+
+// countingStore counts the calls of Get to the underlying mock.URLModel.
+type countingStore struct {
+	mock.URLModel
+	gets int
+}
+
+func (s *countingStore) Get(path string) (*data.URL, error) {
+	s.gets++
+	return s.URLModel.Get(path)
+}
+
+// newTestCache returns a urlCache in front of a countingStore with a controllable clock.
+func newTestCache(size int) (*urlCache, *countingStore, *time.Time) {
+	store := &countingStore{}
+	c := newURLCache(store, size, time.Minute, 5*time.Second)
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	return c, store, &now
+}
+
+func TestURLCacheHit(t *testing.T) {
+	c, store, _ := newTestCache(10)
+	for i := 0; i < 3; i++ {
+		u, err := c.Get("BQRvJsg-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.URL != "https://google.com" {
+			t.Errorf(`want url "https://google.com", got %q`, u.URL)
+		}
+		u.URL = "mutated by caller"
+	}
+	if store.gets != 1 {
+		t.Errorf("want 1 query to the store, got %d", store.gets)
+	}
+}
+
+func TestURLCacheTTL(t *testing.T) {
+	c, store, now := newTestCache(10)
+	c.Get("BQRvJsg-")
+	*now = now.Add(2 * time.Minute)
+	c.Get("BQRvJsg-")
+	if store.gets != 2 {
+		t.Errorf("want 2 queries to the store after ttl, got %d", store.gets)
+	}
+}
+
+func TestURLCacheExpireAt(t *testing.T) {
+	c, store, now := newTestCache(10)
+
+	// The mocked URL expires at 2021, so it is never cached.
+	c.Get("FGeTGg6M")
+	c.Get("FGeTGg6M")
+	if store.gets != 2 {
+		t.Errorf("want expired URL not cached, got %d queries to the store", store.gets)
+	}
+
+	// The mocked URL expires at 2032-12-22 12:00, which is earlier than ttl.
+	*now = time.Date(2032, time.December, 22, 11, 59, 30, 0, time.UTC)
+	c.Get("BQRvJsg-")
+	*now = now.Add(45 * time.Second)
+	c.Get("BQRvJsg-")
+	if store.gets != 4 {
+		t.Errorf("want URL not cached beyond its expire time, got %d queries to the store", store.gets-2)
+	}
+
+	// The mocked URL never expires, so it is cached for ttl.
+	c.Get("N3verExp")
+	*now = now.Add(30 * time.Second)
+	c.Get("N3verExp")
+	if store.gets != 5 {
+		t.Errorf("want never expiring URL cached, got %d queries to the store", store.gets-4)
+	}
+}
+
+func TestURLCacheNegative(t *testing.T) {
+	c, store, now := newTestCache(10)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get("abcd1236"); !errors.Is(err, data.ErrRecordNotFound) {
+			t.Fatalf("want ErrRecordNotFound, got %v", err)
+		}
+	}
+	if store.gets != 1 {
+		t.Errorf("want not found cached, got %d queries to the store", store.gets)
+	}
+
+	*now = now.Add(10 * time.Second)
+	c.Get("abcd1236")
+	if store.gets != 2 {
+		t.Errorf("want not found cached for negative ttl only, got %d queries to the store", store.gets)
+	}
+}
+
+func TestURLCacheInvalidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *urlCache)
+	}{
+		{"insert", func(c *urlCache) { c.Insert(&data.URL{ShortPath: "BQRvJsg-"}) }},
+		{"update", func(c *urlCache) { c.Update(&data.URL{ID: 1, ShortPath: "BQRvJsg-"}) }},
+		{"delete", func(c *urlCache) { c.Delete("BQRvJsg-") }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, store, _ := newTestCache(10)
+			c.Get("BQRvJsg-")
+			test.modify(c)
+			c.Get("BQRvJsg-")
+			if store.gets != 2 {
+				t.Errorf("want cache invalidated, got %d queries to the store", store.gets)
+			}
+		})
+	}
+}
+
+func TestURLCacheEviction(t *testing.T) {
+	c, store, _ := newTestCache(2)
+	c.Get("BQRvJsg-")
+	c.Get("zXWCjacZ")
+	c.Get("BQRvJsg-") // "zXWCjacZ" becomes the least recently used
+	c.Get("zXWCjacZn")
+	if n := c.len(); n != 2 {
+		t.Errorf("want 2 cached entries, got %d", n)
+	}
+
+	c.Get("BQRvJsg-")
+	if store.gets != 3 {
+		t.Errorf("want recently used entry kept, got %d queries to the store", store.gets)
+	}
+	c.Get("zXWCjacZ")
+	if store.gets != 4 {
+		t.Errorf("want least recently used entry evicted, got %d queries to the store", store.gets)
+	}
+}
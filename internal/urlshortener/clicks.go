@@ -0,0 +1,119 @@
+package urlshortener
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// A clickRecorder records clicks in a background goroutine, so that redirects do not wait for the database.
+//
+// Clicks are buffered in a bounded queue and inserted in batches,
+// either when a batch is full or when the flush interval elapses.
+// Clicks arriving when the queue is full are dropped and counted.
+type clickRecorder struct {
+	dropped uint64 // number of dropped clicks, accessed atomically
+
+	queue     chan *data.Click
+	batchSize int
+	interval  time.Duration
+
+	insert   func(clicks []*data.Click) error // inserts a batch of clicks
+	logError func(err error)
+
+	mu     sync.RWMutex // guards closed and closing the queue
+	closed bool
+	done   chan struct{} // closed when the background goroutine returns
+}
+
+// newClickRecorder creates a clickRecorder and starts its background goroutine.
+func newClickRecorder(queueSize, batchSize int, interval time.Duration, insert func([]*data.Click) error, logError func(error)) *clickRecorder {
+	rec := &clickRecorder{
+		queue:     make(chan *data.Click, queueSize),
+		batchSize: batchSize,
+		interval:  interval,
+		insert:    insert,
+		logError:  logError,
+		done:      make(chan struct{}),
+	}
+	go rec.run()
+	return rec
+}
+
+// record enqueues c without blocking.
+// It returns false if c is dropped because the queue is full or the recorder is closed.
+func (rec *clickRecorder) record(c *data.Click) bool {
+	rec.mu.RLock()
+	defer rec.mu.RUnlock()
+	if rec.closed {
+		atomic.AddUint64(&rec.dropped, 1)
+		return false
+	}
+
+	select {
+	case rec.queue <- c:
+		return true
+	default:
+		atomic.AddUint64(&rec.dropped, 1)
+		return false
+	}
+}
+
+// droppedClicks returns the number of clicks dropped so far,
+// including the clicks that failed to be inserted.
+func (rec *clickRecorder) droppedClicks() uint64 {
+	return atomic.LoadUint64(&rec.dropped)
+}
+
+// close stops accepting clicks, flushes all queued clicks and waits for the background goroutine.
+// It is safe to call close more than once.
+func (rec *clickRecorder) close() {
+	rec.mu.Lock()
+	if !rec.closed {
+		rec.closed = true
+		close(rec.queue)
+	}
+	rec.mu.Unlock()
+	<-rec.done
+}
+
+// run collects the queued clicks into batches and flushes them until the queue is closed.
+func (rec *clickRecorder) run() {
+	defer close(rec.done)
+
+	ticker := time.NewTicker(rec.interval)
+	defer ticker.Stop()
+
+	batch := make([]*data.Click, 0, rec.batchSize)
+	for {
+		select {
+		case c, ok := <-rec.queue:
+			if !ok {
+				rec.flush(batch)
+				return
+			}
+			batch = append(batch, c)
+			if len(batch) >= rec.batchSize {
+				rec.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			rec.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush inserts batch. Clicks failed to be inserted are counted as dropped.
+func (rec *clickRecorder) flush(batch []*data.Click) {
+	if len(batch) == 0 {
+		return
+	}
+	err := rec.insert(batch)
+	if err != nil {
+		atomic.AddUint64(&rec.dropped, uint64(len(batch)))
+		rec.logError(err)
+	}
+}
@@ -0,0 +1,120 @@
+package urlshortener
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+	"github.com/Kerseee/urlshortener/internal/data/mock"
+)
+
+func TestClickRecorderBatchSize(t *testing.T) {
+	batches := make(chan int, 10)
+	insert := func(clicks []*data.Click) error {
+		batches <- len(clicks)
+		return nil
+	}
+	rec := newClickRecorder(10, 3, time.Hour, insert, func(error) {})
+	for i := 0; i < 3; i++ {
+		rec.record(&data.Click{URLID: 1})
+	}
+
+	// A full batch is flushed without waiting for the interval.
+	select {
+	case n := <-batches:
+		if n != 3 {
+			t.Errorf("want a batch of 3 clicks, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("want a full batch flushed, got nothing")
+	}
+	rec.close()
+}
+
+func TestClickRecorderFlushInterval(t *testing.T) {
+	clicks := &mock.ClickModel{}
+	rec := newClickRecorder(10, 100, 10*time.Millisecond, clicks.InsertBatch, func(error) {})
+	defer rec.close()
+	rec.record(&data.Click{URLID: 1})
+
+	deadline := time.Now().Add(time.Second)
+	for clicks.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("want the click flushed after the interval, got nothing")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClickRecorderClose(t *testing.T) {
+	clicks := &mock.ClickModel{}
+	rec := newClickRecorder(10, 100, time.Hour, clicks.InsertBatch, func(error) {})
+	for i := 0; i < 5; i++ {
+		rec.record(&data.Click{URLID: 1})
+	}
+	rec.close()
+	rec.close()
+
+	if n := clicks.Len(); n != 5 {
+		t.Errorf("want 5 clicks drained on close, got %d", n)
+	}
+	if rec.record(&data.Click{URLID: 1}) {
+		t.Error("want click rejected after close, got accepted")
+	}
+	if n := rec.droppedClicks(); n != 1 {
+		t.Errorf("want 1 dropped click, got %d", n)
+	}
+}
+
+func TestClickRecorderDrop(t *testing.T) {
+	inserting := make(chan struct{})
+	release := make(chan struct{})
+	insert := func(clicks []*data.Click) error {
+		inserting <- struct{}{}
+		<-release
+		return nil
+	}
+	rec := newClickRecorder(1, 1, time.Hour, insert, func(error) {})
+
+	// The first click blocks the background goroutine in insert,
+	// the second one fills the queue and the third one is dropped.
+	rec.record(&data.Click{URLID: 1})
+	<-inserting
+	if !rec.record(&data.Click{URLID: 2}) {
+		t.Error("want the second click queued, got dropped")
+	}
+	if rec.record(&data.Click{URLID: 3}) {
+		t.Error("want the third click dropped, got queued")
+	}
+	if n := rec.droppedClicks(); n != 1 {
+		t.Errorf("want 1 dropped click, got %d", n)
+	}
+
+	go func() {
+		for range inserting {
+			release <- struct{}{}
+		}
+	}()
+	release <- struct{}{}
+	rec.close()
+	close(inserting)
+}
+
+func TestClickRecorderInsertError(t *testing.T) {
+	var logged error
+	insert := func(clicks []*data.Click) error {
+		return errors.New("insert failed")
+	}
+	rec := newClickRecorder(10, 100, time.Hour, insert, func(err error) { logged = err })
+	rec.record(&data.Click{URLID: 1})
+	rec.record(&data.Click{URLID: 2})
+	rec.close()
+
+	if n := rec.droppedClicks(); n != 2 {
+		t.Errorf("want 2 dropped clicks, got %d", n)
+	}
+	if logged == nil {
+		t.Error("want insert error logged, got nil")
+	}
+}
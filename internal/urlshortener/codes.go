@@ -0,0 +1,116 @@
+package urlshortener
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Kerseee/urlshortener/config"
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// base62Alphabet are the characters of the base62-encoded short paths,
+// which are safe in URLs and not mangled by chat apps.
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// A codeGenerator generates the short paths of the URLs to be shortened.
+type codeGenerator interface {
+	// generate returns a short path of u with n characters, or at most n characters
+	// if the generator cannot control the length.
+	// salt is prepended to u.URL by the generators deriving the short path from u.URL.
+	// It may populate u.ID for inserting u with the id.
+	generate(u *data.URL, salt string, n int) (string, error)
+}
+
+// newCodeGenerator creates the codeGenerator named name, one of config.GeneratorHash,
+// config.GeneratorRandom and config.GeneratorSequential.
+// The sequential generator takes the ids from store.
+func newCodeGenerator(name string, store data.Store) codeGenerator {
+	switch name {
+	case config.GeneratorRandom:
+		return randomGenerator{}
+	case config.GeneratorSequential:
+		return sequentialGenerator{store: store}
+	default:
+		return hashGenerator{}
+	}
+}
+
+// hashGenerator generates the prefix of the SHA-256/base64 of the salted URL,
+// so that the same URL with the same salt is always shortened into the same short path.
+type hashGenerator struct{}
+
+func (hashGenerator) generate(u *data.URL, salt string, n int) (string, error) {
+	return hashAndEncode(salt + u.URL)[:n], nil
+}
+
+// randomGenerator generates random base62 short paths using crypto/rand,
+// so that the short paths cannot be guessed from the URLs.
+type randomGenerator struct{}
+
+func (randomGenerator) generate(u *data.URL, salt string, n int) (string, error) {
+	max := big.NewInt(int64(len(base62Alphabet)))
+	var b strings.Builder
+	b.Grow(n)
+	for i := 0; i < n; i++ {
+		j, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(base62Alphabet[j.Int64()])
+	}
+	return b.String(), nil
+}
+
+// sequentialGenerator generates the base62 encoding of the next id of the URLs from store,
+// so that the short paths are as short as possible.
+// n is ignored, since the length of the short paths grows with the ids.
+type sequentialGenerator struct {
+	store data.Store
+}
+
+func (g sequentialGenerator) generate(u *data.URL, salt string, n int) (string, error) {
+	for {
+		id, err := g.store.NextID()
+		if err != nil {
+			return "", err
+		}
+		if id <= 0 {
+			return "", errors.New("sequential code generator: non-positive id")
+		}
+
+		// Skip the ids encoded into the paths used by the application itself.
+		code := encodeBase62(id)
+		if _, ok := reservedPaths[strings.ToLower(code)]; ok {
+			continue
+		}
+		u.ID = id
+		return code, nil
+	}
+}
+
+// encodeBase62 encodes the positive n in base62.
+func encodeBase62(n int64) string {
+	var b []byte
+	for n > 0 {
+		b = append(b, base62Alphabet[n%62])
+		n /= 62
+	}
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// newSalt returns a random salt of the owner ownerID,
+// which makes the short paths derived from the URLs distinct from the unsalted ones.
+func newSalt(ownerID int64) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d:%s:", ownerID, hex.EncodeToString(nonce)), nil
+}
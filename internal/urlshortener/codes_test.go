@@ -0,0 +1,95 @@
+package urlshortener
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+	"github.com/Kerseee/urlshortener/internal/data/mock"
+)
+
+func TestEncodeBase62(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{1, "1"},
+		{61, "z"},
+		{62, "10"},
+		{3843, "zz"},
+		{3844, "100"},
+	}
+
+	for _, test := range tests {
+		if got := encodeBase62(test.n); got != test.want {
+			t.Errorf("encodeBase62(%d): want %q, got %q", test.n, test.want, got)
+		}
+	}
+}
+
+func TestCodeGenerators(t *testing.T) {
+	base62Exp := regexp.MustCompile(`^[0-9A-Za-z]+$`)
+
+	// The hash generator is deterministic.
+	u := &data.URL{URL: "https://google.com"}
+	if got, _ := (hashGenerator{}).generate(u, "", 8); got != "BQRvJsg-" {
+		t.Errorf(`want hash code "BQRvJsg-", got %q`, got)
+	}
+
+	// The random generator generates different base62 codes.
+	seen := make(map[string]struct{})
+	for i := 0; i < 100; i++ {
+		code, err := (randomGenerator{}).generate(u, "", 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 8 || !base62Exp.MatchString(code) {
+			t.Fatalf("want 8-character base62 code, got %q", code)
+		}
+		seen[code] = struct{}{}
+	}
+	if len(seen) < 100 {
+		t.Errorf("want 100 distinct random codes, got %d", len(seen))
+	}
+
+	// The sequential generator encodes the next id and populates u.ID.
+	g := sequentialGenerator{store: &mock.URLModel{}}
+	first, err := g.generate(u, "", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstID := u.ID
+	second, _ := g.generate(u, "", 8)
+	if u.ID != firstID+1 || first != encodeBase62(firstID) || second != encodeBase62(firstID+1) {
+		t.Errorf("want sequential codes of ids %d and %d, got %q and %q with id %d", firstID, firstID+1, first, second, u.ID)
+	}
+}
+
+// fixedIDs returns the ids in order from NextID.
+type fixedIDs struct {
+	mock.URLModel
+	ids []int64
+}
+
+func (s *fixedIDs) NextID() (int64, error) {
+	id := s.ids[0]
+	s.ids = s.ids[1:]
+	return id, nil
+}
+
+func TestSequentialGeneratorSkipsReservedPaths(t *testing.T) {
+	apiID := int64(36*62*62 + 51*62 + 44)
+	if code := encodeBase62(apiID); code != "api" {
+		t.Fatalf(`want id %d encoded into "api", got %q`, apiID, code)
+	}
+
+	u := &data.URL{URL: "https://google.com"}
+	g := sequentialGenerator{store: &fixedIDs{ids: []int64{apiID, apiID + 1}}}
+	code, err := g.generate(u, "", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != encodeBase62(apiID+1) || u.ID != apiID+1 {
+		t.Errorf("want reserved path skipped to id %d, got %q with id %d", apiID+1, code, u.ID)
+	}
+}
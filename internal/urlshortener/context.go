@@ -0,0 +1,61 @@
+package urlshortener
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// contextKey is the type of the keys of the values stored in request contexts.
+type contextKey string
+
+const (
+	requestIDContextKey  = contextKey("requestID")
+	requestLogContextKey = contextKey("requestLog")
+	apiKeyContextKey     = contextKey("apiKey")
+)
+
+// contextSetRequestID returns a copy of r with the request ID id stored in its context.
+func contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestID returns the request ID of r, or an empty string if there is none.
+func contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// contextSetRequestLog returns a copy of r with l stored in its context.
+func contextSetRequestLog(r *http.Request, l *requestLog) *http.Request {
+	ctx := context.WithValue(r.Context(), requestLogContextKey, l)
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestLog returns the requestLog of r, or nil if there is none.
+func contextGetRequestLog(r *http.Request) *requestLog {
+	l, _ := r.Context().Value(requestLogContextKey).(*requestLog)
+	return l
+}
+
+// contextSetAPIKey returns a copy of r with the authenticated API key k stored in its context.
+func contextSetAPIKey(r *http.Request, k *data.APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey, k)
+	return r.WithContext(ctx)
+}
+
+// contextGetAPIKey returns the authenticated API key of r, or nil if r is not authenticated.
+func contextGetAPIKey(r *http.Request) *data.APIKey {
+	k, _ := r.Context().Value(apiKeyContextKey).(*data.APIKey)
+	return k
+}
+
+// contextGetOwnerID returns the id of the authenticated API key of r, or 0 if r is not authenticated.
+func contextGetOwnerID(r *http.Request) int64 {
+	if k := contextGetAPIKey(r); k != nil {
+		return k.ID
+	}
+	return 0
+}
@@ -0,0 +1,122 @@
+package urlshortener
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	errBlockedDomain    = errors.New("url domain is blocked")
+	errNotAllowedDomain = errors.New("url domain is not allowed")
+)
+
+// domainLists holds the blocked and the allowed domains of the destination URLs, which are loaded from files.
+// A domain in the lists also matches its subdomains. All domains not blocked are allowed if there is no allowlist,
+// while an allowlist file without any domain allows none.
+// It is safe for concurrent use, and the lists can be reloaded while serving.
+type domainLists struct {
+	blocklistPath string // path of the blocklist file, empty if there is no blocklist
+	allowlistPath string // path of the allowlist file, empty if there is no allowlist
+
+	mu      sync.RWMutex
+	blocked map[string]struct{}
+	allowed map[string]struct{} // nil if there is no allowlist
+}
+
+// newDomainLists creates the domainLists loaded from the files at blocklistPath and allowlistPath.
+// An empty path disables the corresponding list.
+func newDomainLists(blocklistPath, allowlistPath string) (*domainLists, error) {
+	d := &domainLists{blocklistPath: blocklistPath, allowlistPath: allowlistPath}
+	if err := d.reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// reload reads the lists from their files again. The lists are unchanged if either file cannot be read.
+func (d *domainLists) reload() error {
+	blocked, err := readDomainFile(d.blocklistPath)
+	if err != nil {
+		return err
+	}
+	allowed, err := readDomainFile(d.allowlistPath)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.blocked, d.allowed = blocked, allowed
+	return nil
+}
+
+// check returns errBlockedDomain if host or its parent domain is blocked,
+// or errNotAllowedDomain if there is an allowlist and neither host nor its parent domain is in it.
+// A nil domainLists allows all hosts.
+func (d *domainLists) check(host string) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if matchDomain(d.blocked, host) {
+		return errBlockedDomain
+	}
+	if d.allowed != nil && !matchDomain(d.allowed, host) {
+		return errNotAllowedDomain
+	}
+	return nil
+}
+
+// matchDomain reports whether host or any of its parent domains is in domains.
+func matchDomain(domains map[string]struct{}, host string) bool {
+	host = normalizeDomain(host)
+	for host != "" {
+		if _, ok := domains[host]; ok {
+			return true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return false
+}
+
+// readDomainFile reads the domains in the file at path, one per line.
+// Empty lines and lines starting with '#' are ignored, and a leading "*." is trimmed.
+// It returns nil if path is empty.
+func readDomainFile(path string) (map[string]struct{}, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	domains := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domain := normalizeDomain(strings.TrimPrefix(line, "*."))
+		if domain != "" {
+			domains[domain] = struct{}{}
+		}
+	}
+	return domains, scanner.Err()
+}
+
+// normalizeDomain lowercases domain and trims its trailing dot.
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(domain), ".")
+}
@@ -0,0 +1,112 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeDomainFile writes content into the file named name in dir and returns its path.
+func writeDomainFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDomainLists(t *testing.T) {
+	dir := t.TempDir()
+	blocklist := writeDomainFile(t, dir, "blocklist", "# phishing\nevil.com\n*.Bad.Example.com.\n\n")
+	allowlist := writeDomainFile(t, dir, "allowlist", "example.com\ngo.dev\n")
+	emptyAllowlist := writeDomainFile(t, dir, "empty-allowlist", "")
+	commentAllowlist := writeDomainFile(t, dir, "comment-allowlist", "# go.dev\n\n")
+
+	tests := []struct {
+		name      string
+		blocklist string
+		allowlist string
+		host      string
+		wantErr   error
+	}{
+		{"no list", "", "", "evil.com", nil},
+		{"blocked domain", blocklist, "", "evil.com", errBlockedDomain},
+		{"blocked subdomain", blocklist, "", "www.evil.com", errBlockedDomain},
+		{"blocked wildcard domain in other case", blocklist, "", "BAD.example.com", errBlockedDomain},
+		{"similar domain not blocked", blocklist, "", "notevil.com", nil},
+		{"allowed domain", "", allowlist, "go.dev", nil},
+		{"allowed subdomain", "", allowlist, "pkg.go.dev", nil},
+		{"domain not allowed", "", allowlist, "google.com", errNotAllowedDomain},
+		{"blocked subdomain of allowed domain", blocklist, allowlist, "bad.example.com", errBlockedDomain},
+		{"empty allowlist", "", emptyAllowlist, "go.dev", errNotAllowedDomain},
+		{"comments-only allowlist", "", commentAllowlist, "go.dev", errNotAllowedDomain},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := newDomainLists(test.blocklist, test.allowlist)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := d.check(test.host); !errors.Is(err, test.wantErr) {
+				t.Errorf("want %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := newDomainLists(filepath.Join(dir, "missing"), ""); err == nil {
+		t.Error("want error for missing blocklist, got nil")
+	}
+	var d *domainLists
+	if err := d.check("evil.com"); err != nil {
+		t.Errorf("want nil domainLists allowing all hosts, got %v", err)
+	}
+}
+
+func TestDomainListsReload(t *testing.T) {
+	dir := t.TempDir()
+	blocklist := writeDomainFile(t, dir, "blocklist", "evil.com\n")
+	d, err := newDomainLists(blocklist, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app, _ := newTestApp(t)
+	app.domains = d
+	if err := app.validateURL("https://evil.com/login"); !errors.Is(err, errBlockedDomain) {
+		t.Fatalf("want %v, got %v", errBlockedDomain, err)
+	}
+
+	// The lists are reloaded on SIGHUP.
+	stop := app.startReloader(context.Background())
+	defer stop()
+	writeDomainFile(t, dir, "blocklist", "phishing.com\n")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for d.check("evil.com") != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := d.check("evil.com"); err != nil {
+		t.Errorf("want evil.com unblocked after reload, got %v", err)
+	}
+	if err := d.check("phishing.com"); !errors.Is(err, errBlockedDomain) {
+		t.Errorf("want phishing.com blocked after reload, got %v", err)
+	}
+
+	// The lists are kept if the file cannot be read.
+	if err := os.Remove(blocklist); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.reload(); err == nil {
+		t.Error("want error reloading missing blocklist, got nil")
+	}
+	if err := d.check("phishing.com"); !errors.Is(err, errBlockedDomain) {
+		t.Errorf("want phishing.com still blocked, got %v", err)
+	}
+}
@@ -2,12 +2,19 @@ package urlshortener
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 var (
 	// ErrRequestBodyTooLarge describe the error in http.MaxBytesReader
 	ErrRequestBodyTooLarge = errors.New("http: request body too large")
+
+	errAliasConflict    = errors.New("alias is already in use")
+	errShortURLConflict = errors.New("server internal error: short URL conflict")
+	errBatchTimeout     = errors.New("batch timed out before shortening this url, please try again")
 )
 
 // InternalError wrap an error with customized error message Msg and origin error Err.
@@ -25,7 +32,7 @@ func (app *App) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request)
 	msg := envelop{"error": "this method is not allowed"}
 	err := writeJSON(w, http.StatusMethodNotAllowed, msg, nil)
 	if err != nil {
-		app.logError(err)
+		app.logError(r, err)
 	}
 }
 
@@ -34,18 +41,53 @@ func (app *App) badRequestResponse(w http.ResponseWriter, r *http.Request, err e
 	msg := envelop{"error": err.Error()}
 	err = writeJSON(w, http.StatusBadRequest, msg, nil)
 	if err != nil {
-		app.logError(err)
+		app.logError(r, err)
 	}
 }
 
 // serverErrorResponse informs the client of server internal error.
 func (app *App) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logError(err)
+	app.logError(r, err)
 
 	msg := envelop{"error": "server cannot process your request now"}
 	err = writeJSON(w, http.StatusInternalServerError, msg, nil)
 	if err != nil {
-		app.logError(err)
+		app.logError(r, err)
+	}
+}
+
+// pendingResponse informs the client that the requested shortened URL is not active yet,
+// by the placeholder page if configured or by 404 Not Found as if the shortened URL does not exist.
+// The response should not be cached, since the shortened URL will redirect once active.
+func (app *App) pendingResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if app.placeholder == nil {
+		app.recordNotFoundResponse(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(app.placeholder); err != nil {
+		app.logError(r, err)
+	}
+}
+
+// scanUnavailableResponse informs the client that the url cannot be shortened now since it cannot be scanned.
+func (app *App) scanUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	msg := envelop{"error": errScanUnavailable.Error()}
+	err := writeJSON(w, http.StatusServiceUnavailable, msg, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// goneResponse informs the client that the requested shortened URL has reached its click limit.
+func (app *App) goneResponse(w http.ResponseWriter, r *http.Request) {
+	msg := envelop{"error": "the shortened URL has reached its click limit"}
+	err := writeJSON(w, http.StatusGone, msg, nil)
+	if err != nil {
+		app.logError(r, err)
 	}
 }
 
@@ -54,6 +96,67 @@ func (app *App) recordNotFoundResponse(w http.ResponseWriter, r *http.Request) {
 	msg := envelop{"error": "record not found or expired"}
 	err := writeJSON(w, http.StatusNotFound, msg, nil)
 	if err != nil {
-		app.logError(err)
+		app.logError(r, err)
+	}
+}
+
+// aliasConflictResponse informs the client that the requested alias is already in use.
+func (app *App) aliasConflictResponse(w http.ResponseWriter, r *http.Request) {
+	msg := envelop{"error": "alias is already in use"}
+	err := writeJSON(w, http.StatusConflict, msg, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// notReadyResponse informs the client that the server is not ready to serve requests because of reason.
+func (app *App) notReadyResponse(w http.ResponseWriter, r *http.Request, reason string) {
+	msg := envelop{"status": "unavailable", "error": reason}
+	err := writeJSON(w, http.StatusServiceUnavailable, msg, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// authenticationRequiredResponse informs the client that an API key is required.
+func (app *App) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	msg := envelop{"error": "you must provide an API key to access this resource"}
+	headers := http.Header{"Www-Authenticate": []string{"Bearer"}}
+	err := writeJSON(w, http.StatusUnauthorized, msg, headers)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// invalidAPIKeyResponse informs the client that the provided API key is invalid.
+func (app *App) invalidAPIKeyResponse(w http.ResponseWriter, r *http.Request) {
+	msg := envelop{"error": "invalid or missing API key"}
+	headers := http.Header{"Www-Authenticate": []string{"Bearer"}}
+	err := writeJSON(w, http.StatusUnauthorized, msg, headers)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// notPermittedResponse informs the client that it is not permitted to manage the requested record.
+func (app *App) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	msg := envelop{"error": "your API key is not permitted to manage this record"}
+	err := writeJSON(w, http.StatusForbidden, msg, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// rateLimitExceededResponse informs the client that it sends too many requests and should retry after wait.
+func (app *App) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	msg := envelop{"error": "rate limit exceeded"}
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	headers := http.Header{"Retry-After": []string{strconv.Itoa(retryAfter)}}
+	err := writeJSON(w, http.StatusTooManyRequests, msg, headers)
+	if err != nil {
+		app.logError(r, err)
 	}
 }
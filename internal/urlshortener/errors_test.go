@@ -36,7 +36,7 @@ func TestMethodNotAllowedResponse(t *testing.T) {
 		{"Connect", http.MethodConnect},
 	}
 
-	app, _ := newTestApp()
+	app, _ := newTestApp(t)
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			// Send a request.
@@ -67,7 +67,7 @@ func TestBadRequestResponse(t *testing.T) {
 	}
 
 	// Send a request.
-	app, _ := newTestApp()
+	app, _ := newTestApp(t)
 	r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/", bytes.NewBuffer([]byte("some request")))
 	w := httptest.NewRecorder()
 	app.badRequestResponse(w, r, want.err)
@@ -95,7 +95,7 @@ func TestServerErrorResponse(t *testing.T) {
 	}
 
 	// Send a request.
-	app, logger := newTestApp()
+	app, logger := newTestApp(t)
 	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil)
 	w := httptest.NewRecorder()
 	app.serverErrorResponse(w, r, want.err)
@@ -128,7 +128,7 @@ func TestRecordNotFoundResponse(t *testing.T) {
 	}
 
 	// Send a request.
-	app, _ := newTestApp()
+	app, _ := newTestApp(t)
 	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/some-end-point?query=something", nil)
 	w := httptest.NewRecorder()
 	app.recordNotFoundResponse(w, r)
@@ -139,3 +139,27 @@ func TestRecordNotFoundResponse(t *testing.T) {
 	validateHeader(t, want.header, header)
 	validateBodyContains(t, want.body, string(body))
 }
+
+func TestAliasConflictResponse(t *testing.T) {
+	want := struct {
+		code   int
+		header http.Header
+		body   string
+	}{
+		code:   http.StatusConflict,
+		header: http.Header{"Content-Type": []string{"application/json"}},
+		body:   "alias is already in use",
+	}
+
+	// Send a request.
+	app, _ := newTestApp(t)
+	r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/urls", nil)
+	w := httptest.NewRecorder()
+	app.aliasConflictResponse(w, r)
+
+	// Check the response.
+	code, header, body := getResponse(t, w)
+	validateCode(t, want.code, code)
+	validateHeader(t, want.header, header)
+	validateBodyContains(t, want.body, string(body))
+}
@@ -1,15 +1,42 @@
 package urlshortener
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Kerseee/urlshortener/internal/data"
 )
 
-const maxRequestBody int64 = 1 << 20 // 1MB
+const (
+	maxRequestBody int64 = 1 << 20 // 1MB
+
+	urlsPath  = "/api/v1/urls/"      // prefix of the end points managing a shortened URL
+	batchPath = "/api/v1/urls/batch" // end point shortening a batch of urls
+
+	maxBatchSize = 1000 // maximum number of urls shortened in a batch
+
+	directReferrer = "direct" // referrer shown in the click statistics for clicks without referrer
+)
+
+// A urlInput is a url to be shortened in the request body.
+type urlInput struct {
+	URL          string     `json:"url"`
+	ExpireAt     *time.Time `json:"expireAt"`     // config.Expire.DefaultTTL from now or activeFrom if omitted
+	ActiveFrom   *time.Time `json:"activeFrom"`   // the shortened URL does not redirect before activeFrom
+	NeverExpires bool       `json:"neverExpires"` // the shortened URL never expires if true
+	Alias        string     `json:"alias"`
+	RedirectCode int        `json:"redirectCode"`
+	Distinct     bool       `json:"distinct"`  // shorten into a new short path even if the url has been shortened
+	Password     string     `json:"password"`  // password protecting the redirect, always shortened into a new short path
+	MaxClicks    int64      `json:"maxClicks"` // maximum number of redirects, always shortened into a new short path
+
+	AlwaysPreview bool `json:"alwaysPreview"` // show the preview page before every redirect
+}
 
 // registerURL extracts the to-shorten url from the request, shortens the url,
 // and writes the shortened url into response.
@@ -21,10 +48,7 @@ func (app *App) registerURL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read the request body.
-	var input struct {
-		URL      string    `json:"url"`
-		ExpireAt time.Time `json:"expireAt"`
-	}
+	var input urlInput
 	err := readJSON(w, r, &input)
 	if err != nil {
 		var internalErr *InternalError
@@ -38,83 +62,297 @@ func (app *App) registerURL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate input.
-	var errs []string
-	if err := validateURL(input.URL); err != nil {
-		errs = append(errs, err.Error())
-	}
-	if err := validateExpireTime(input.ExpireAt); err != nil {
-		errs = append(errs, err.Error())
-	}
-	if len(errs) > 0 {
+	if errs := app.validateURLInput(&input); len(errs) > 0 {
 		writeJSON(w, http.StatusBadRequest, envelop{"error": errs}, nil)
 		return
 	}
 
-	// Shorten the url.
-	shortPath, err := app.shortenURL(input.URL)
+	// Shorten and insert the url.
+	u, err := app.newURL(r, &input)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
-
-	// Insert the url.
-	u := data.URL{
-		URL:       input.URL,
-		ExpireAt:  input.ExpireAt,
-		ShortPath: shortPath,
+	err = app.scanURL(r, u)
+	if err == nil {
+		err = app.createURL(u, input.Distinct)
 	}
-	err = app.urlModel.Insert(&u)
 	if err != nil {
 		switch {
-		case errors.Is(err, data.ErrDuplicateShortUrl):
-			// Get the record that has the same shortUrl.
-			record, recordErr := app.urlModel.Get(shortPath)
-			if recordErr != nil {
-				app.serverErrorResponse(w, r, recordErr)
-				return
-			}
-
-			// If the origin URL does not equal record.URL, then reshorten the URL.
-			if record.URL != u.URL {
-				app.reShortenURL(w, r, &u)
-				return
-			}
-
-			// Otherwise, check the expire time.
-			// If the expire time is later than record's expire time, then update it.
-			if record.ExpireAt.Before(u.ExpireAt) {
-				u.ID = record.ID
-				err := app.urlModel.Update(&u)
-				if err != nil {
-					app.serverErrorResponse(w, r, err)
-					return
-				}
-			}
+		case errors.Is(err, errMaliciousURL):
+			app.badRequestResponse(w, r, err)
+		case errors.Is(err, errScanUnavailable):
+			app.scanUnavailableResponse(w, r)
+		case errors.Is(err, errAliasConflict):
+			app.aliasConflictResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
-			return
 		}
+		return
 	}
 
 	// Write the short URL back.
 	app.writeShortURL(w, r, u.ShortPath)
 }
 
-// redirect extracts the shortened URL in the request and redirects to the corresponding origin URL.
-// If the shortened URL is not found or is found but expired, then send 404 not found to the client.
-func (app *App) redirect(w http.ResponseWriter, r *http.Request) {
+// registerURLs shortens a batch of urls from the request, and writes the results into response
+// in the same order as the urls in the request.
+//
+// The urls are shortened one by one and inserted at once,
+// so that an invalid or conflicting url does not fail the whole batch.
+// The result of a url is either its short URL or the list of errors shortening it.
+func (app *App) registerURLs(w http.ResponseWriter, r *http.Request) {
 	// Check if the method is allowed.
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodPost {
 		app.methodNotAllowedResponse(w, r)
 		return
 	}
 
+	// Read the request body.
+	var input []urlInput
+	err := readJSON(w, r, &input)
+	if err != nil {
+		var internalErr *InternalError
+		switch {
+		case errors.As(err, &internalErr):
+			app.serverErrorResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	if len(input) == 0 || len(input) > maxBatchSize {
+		app.badRequestResponse(w, r, fmt.Errorf("batch should contain 1 to %d urls", maxBatchSize))
+		return
+	}
+
+	// The request has taken a token of the create limiter for the first url.
+	// Take the tokens for the other urls before shortening any, so that a batch does not exceed the rate limit.
+	// The token of the request is given back if the batch is rejected.
+	if l := app.createLimiter; l != nil && len(input) > 1 {
+		key := app.rateLimitKey(r)
+		if burst := int(l.burst); len(input) > burst {
+			l.refund(key)
+			app.badRequestResponse(w, r, fmt.Errorf("batch should contain at most %d urls under the rate limit", burst))
+			return
+		}
+		if ok, wait := l.allowN(key, len(input)-1); !ok {
+			l.refund(key)
+			app.rateLimitExceededResponse(w, r, wait)
+			return
+		}
+	}
+
+	// Shorten and insert the urls within app.config.BatchTimeout, so that slow scanners cannot hold the request.
+	// The urls left after the deadline are not shortened.
+	ctx := r.Context()
+	if app.config.BatchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, app.config.BatchTimeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+	// Shorten the urls one by one.
+	results := make([]envelop, len(input))
+	prepared := make([]*pendingURL, len(input))
+	for i := range input {
+		if ctx.Err() != nil {
+			results[i] = envelop{"error": []string{errBatchTimeout.Error()}}
+			continue
+		}
+		if errs := app.validateURLInput(&input[i]); len(errs) > 0 {
+			results[i] = envelop{"error": errs}
+			continue
+		}
+		if input[i].Password != "" {
+			results[i] = envelop{"error": []string{"password is not supported in batch"}}
+			continue
+		}
+
+		u, err := app.newURL(r, &input[i])
+		if err == nil {
+			err = app.scanURL(r, u)
+		}
+		if err == nil && ctx.Err() != nil {
+			// The scan may have been cut short by the deadline.
+			err = errBatchTimeout
+		}
+		if err == nil {
+			prepared[i], err = app.prepareURL(u, input[i].Distinct)
+		}
+		if err != nil {
+			results[i] = app.batchErrorResult(r, err)
+		}
+	}
+
+	// Insert the shortened urls at once, and settle the conflicts one by one.
+	var us []*data.URL
+	var indexes []int
+	for i, c := range prepared {
+		if c != nil {
+			us = append(us, c.u)
+			indexes = append(indexes, i)
+		}
+	}
+	insertErrs := app.urlModel.InsertBatch(us)
+	for j, i := range indexes {
+		if err := app.settleURL(prepared[i], insertErrs[j]); err != nil {
+			results[i] = app.batchErrorResult(r, err)
+			continue
+		}
+		u := prepared[i].u
+		results[i] = envelop{"id": u.ShortPath, "shortUrl": app.shortURL(u.ShortPath)}
+	}
+
+	err = writeJSON(w, http.StatusOK, envelop{"results": results}, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// batchErrorResult returns the result of a url failed with err in a batch.
+// The unexpected errors are logged and hidden from the client.
+func (app *App) batchErrorResult(r *http.Request, err error) envelop {
+	switch {
+	case errors.Is(err, errAliasConflict), errors.Is(err, errMaliciousURL), errors.Is(err, errScanUnavailable),
+		errors.Is(err, errBatchTimeout):
+		return envelop{"error": []string{err.Error()}}
+	default:
+		app.logError(r, err)
+		return envelop{"error": []string{"server cannot process this url now"}}
+	}
+}
+
+// createURL inserts u and populates u.ShortPath.
+//
+// If u.ShortPath is empty, then u.URL is shortened into a short path,
+// which is reused if u.URL has already been shortened by the same owner unless distinct is true.
+// If distinct is true or u is exclusive, u.URL is salted with the owner and a random nonce,
+// so that u gets its own short path, clicks and expire time.
+// Otherwise, u.ShortPath is a client-requested alias,
+// and errAliasConflict is returned if the alias is taken by an unexpired record.
+func (app *App) createURL(u *data.URL, distinct bool) error {
+	c, err := app.prepareURL(u, distinct)
+	if err != nil {
+		return err
+	}
+	return app.settleURL(c, app.urlModel.Insert(u))
+}
+
+// A pendingURL is a URL shortened by prepareURL and waiting to be inserted.
+type pendingURL struct {
+	u        *data.URL
+	alias    bool   // u.ShortPath is a client-requested alias
+	distinct bool   // u gets its own short path
+	salt     string // salt shortening u.URL if distinct
+}
+
+// prepareURL shortens u.URL into u.ShortPath unless u.ShortPath is a client-requested alias,
+// and returns the pendingURL to be inserted and settled by settleURL as createURL does.
+func (app *App) prepareURL(u *data.URL, distinct bool) (*pendingURL, error) {
+	if u.ShortPath != "" {
+		return &pendingURL{u: u, alias: true}, nil
+	}
+
+	// Shorten the url.
+	c := &pendingURL{u: u, distinct: distinct || exclusive(u)}
+	if c.distinct {
+		var err error
+		if c.salt, err = newSalt(u.OwnerID); err != nil {
+			return nil, err
+		}
+	}
+	shortPath, err := app.shortenURL(u, c.salt)
+	if err != nil {
+		return nil, err
+	}
+	u.ShortPath = shortPath
+	return c, nil
+}
+
+// settleURL settles the pendingURL c with the error err of inserting it.
+// A conflicting alias returns errAliasConflict, and a conflicting short path is reused or reshortened.
+func (app *App) settleURL(c *pendingURL, err error) error {
+	u := c.u
+	if c.alias {
+		return app.settleAlias(err)
+	}
+	switch {
+	case err == nil:
+		app.metrics.shorten(shortenNew)
+		return nil
+	case !errors.Is(err, data.ErrDuplicateShortUrl):
+		return err
+	case c.distinct:
+		return app.reShortenURL(u, c.salt)
+	}
+
+	// Get the record that has the same shortUrl.
+	// It is unexpired, since Insert reclaims the short paths of expired records.
+	record, err := app.urlModel.Get(u.ShortPath)
+	if err != nil {
+		return err
+	}
+
+	// If the origin URL does not equal record.URL, the record is owned by another client,
+	// the record redirects with another status code or from another active time,
+	// the record is flagged or previewed differently or the record is exclusive, then reshorten the URL.
+	if record.URL != u.URL || record.OwnerID != u.OwnerID || record.RedirectCode != u.RedirectCode ||
+		!record.ActiveFrom.Equal(u.ActiveFrom) || record.Flagged != u.Flagged || record.AlwaysPreview != u.AlwaysPreview ||
+		exclusive(record) {
+		return app.reShortenURL(u, c.salt)
+	}
+	app.metrics.shorten(shortenDuplicate)
+
+	// Otherwise, check the expire time.
+	// If the expire time is later than record's expire time, then update it.
+	// A zero expire time never expires, so it is later than any other expire time.
+	if !record.ExpireAt.IsZero() && (u.ExpireAt.IsZero() || record.ExpireAt.Before(u.ExpireAt)) {
+		u.ID = record.ID
+		return app.urlModel.Update(u)
+	}
+	return nil
+}
+
+// settleAlias settles the client-requested alias with the error err of inserting it.
+//
+// If the alias is taken by an expired record, then the storage backend reclaims the alias.
+// If the alias is taken by an unexpired record, then errAliasConflict is returned.
+func (app *App) settleAlias(err error) error {
+	switch {
+	case err == nil:
+		app.metrics.shorten(shortenNew)
+		return nil
+	case errors.Is(err, data.ErrDuplicateShortUrl):
+		app.metrics.shorten(shortenAliasConflict)
+		return errAliasConflict
+	default:
+		return err
+	}
+}
+
+// redirect extracts the shortened URL in the request and redirects to the corresponding origin URL
+// with the redirect code of the shortened URL.
+// If the shortened URL is not found or is found but expired, then send 404 not found to the client.
+func (app *App) redirect(w http.ResponseWriter, r *http.Request) {
 	// Extracts the URL instance.
-	path := strings.TrimPrefix(r.URL.Path, "/")
+	// A short path followed by "+" asks for the preview page of the URL.
+	path, plus := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/"), "+")
 	u, err := app.urlModel.Get(path)
+
+	// Check if the method is allowed.
+	// Methods other than GET are only allowed on the URLs redirected with a method-preserving status code,
+	// except that POST submits the password of a password-protected URL.
+	if r.Method != http.MethodGet && (err == nil || errors.Is(err, data.ErrRecordNotFound)) {
+		if u == nil || plus || !preservesMethod(app.redirectCode(u)) && !(u.Protected() && r.Method == http.MethodPost) {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
+			app.metrics.redirect(redirectMiss)
 			app.recordNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -123,11 +361,365 @@ func (app *App) redirect(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if the URL is expired.
-	if u.ExpireAt.Before(time.Now()) {
+	if u.Expired(time.Now()) {
+		app.metrics.redirect(redirectExpired)
+		app.recordNotFoundResponse(w, r)
+		return
+	}
+
+	// Check if the URL is active yet.
+	if u.Pending(time.Now()) {
+		app.metrics.redirect(redirectPending)
+		app.pendingResponse(w, r)
+		return
+	}
+
+	// Check if the URL has used up its clicks.
+	// The ClickCount may lag behind, so the click limit is checked again when the click is counted.
+	if u.MaxClicks > 0 && u.ClickCount >= u.MaxClicks {
+		app.metrics.redirect(redirectExhausted)
+		app.goneResponse(w, r)
+		return
+	}
+
+	// Show the preview page if it is asked for, or until the visitors choose to continue if the URL is always previewed.
+	// Only GET requests from browsers are previewed.
+	query := r.URL.Query()
+	if r.Method == http.MethodGet && (plus || query.Get("preview") == "1" || u.AlwaysPreview && query.Get("continue") != "1") {
+		app.metrics.redirect(redirectPreviewed)
+		app.previewResponse(w, r, u)
+		return
+	}
+
+	// Warn the visitors of a flagged URL until they choose to continue.
+	// Only GET requests from browsers are warned.
+	if u.Flagged && r.Method == http.MethodGet && query.Get("continue") != "1" {
+		app.metrics.redirect(redirectWarned)
+		app.warningResponse(w, r, u)
+		return
+	}
+
+	// Ask for the password if the URL is protected.
+	if u.Protected() {
+		app.unlockURL(w, r, u)
+		return
+	}
+	app.redirectTo(w, r, u, app.redirectCode(u))
+}
+
+// redirectTo counts and records the click of u, and redirects the client to u.URL with code.
+// A URL with MaxClicks is responded with 410 Gone instead once its clicks are used up.
+func (app *App) redirectTo(w http.ResponseWriter, r *http.Request, u *data.URL, code int) {
+	// Count the click against the click limit.
+	if u.MaxClicks > 0 {
+		err := app.urlModel.UseClick(u.ID)
+		switch {
+		case errors.Is(err, data.ErrClickLimitReached):
+			app.metrics.redirect(redirectExhausted)
+			app.goneResponse(w, r)
+			return
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.metrics.redirect(redirectMiss)
+			app.recordNotFoundResponse(w, r)
+			return
+		case err != nil:
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// Record the click and redirect to the origin URL.
+	app.metrics.redirect(redirectHit)
+	app.recordClick(r, u)
+	http.Redirect(w, r, u.URL, code)
+}
+
+// manageURL dispatches the requests to "/api/v1/urls/:id" and "/api/v1/urls/:id/stats" by their methods,
+// where id is the short path of the shortened URL.
+func (app *App) manageURL(w http.ResponseWriter, r *http.Request) {
+	id, sub := splitURLsPath(r.URL.Path)
+	switch {
+	case id == "" || strings.Contains(sub, "/"):
 		app.recordNotFoundResponse(w, r)
 		return
+	case sub == "stats":
+		if r.Method != http.MethodGet {
+			app.methodNotAllowedResponse(w, r)
+			return
+		}
+		app.showStats(w, r, id)
+		return
+	case sub != "":
+		app.recordNotFoundResponse(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app.showURL(w, r, id)
+	case http.MethodPatch:
+		app.updateURL(w, r, id)
+	case http.MethodDelete:
+		app.deleteURL(w, r, id)
+	default:
+		app.methodNotAllowedResponse(w, r)
+	}
+}
+
+// showURL writes the shortened URL having the short path id into response.
+// Expired shortened URLs are also shown so that clients can extend their expire time.
+func (app *App) showURL(w http.ResponseWriter, r *http.Request, id string) {
+	u, err := app.urlModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !app.canManage(r, u) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	app.writeURL(w, r, u)
+}
+
+// updateURL updates the origin url and/or the expire time of the shortened URL having the short path id.
+func (app *App) updateURL(w http.ResponseWriter, r *http.Request, id string) {
+	// Read the request body.
+	var input struct {
+		URL           *string    `json:"url"`
+		ExpireAt      *time.Time `json:"expireAt"`
+		NeverExpires  bool       `json:"neverExpires"`
+		ActiveFrom    *time.Time `json:"activeFrom"`
+		AlwaysPreview *bool      `json:"alwaysPreview"`
+	}
+	err := readJSON(w, r, &input)
+	if err != nil {
+		var internalErr *InternalError
+		switch {
+		case errors.As(err, &internalErr):
+			app.serverErrorResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	// Validate input.
+	var errs []string
+	if input.URL == nil && input.ExpireAt == nil && !input.NeverExpires && input.ActiveFrom == nil && input.AlwaysPreview == nil {
+		errs = append(errs, "at least one of url, expireAt, neverExpires, activeFrom and alwaysPreview should be provided")
+	}
+	if input.ExpireAt != nil && input.NeverExpires {
+		errs = append(errs, "expireAt and neverExpires should not be both provided")
+	}
+	if input.URL != nil {
+		if err := app.validateURL(*input.URL); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if input.ExpireAt != nil {
+		if err := validateExpireTime(*input.ExpireAt); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		writeJSON(w, http.StatusBadRequest, envelop{"error": errs}, nil)
+		return
+	}
+
+	// Get the record.
+	u, err := app.urlModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !app.canManage(r, u) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	// Update the record.
+	if input.URL != nil {
+		u.URL = *input.URL
+		if err := app.scanURL(r, u); err != nil {
+			switch {
+			case errors.Is(err, errScanUnavailable):
+				app.scanUnavailableResponse(w, r)
+			default:
+				app.badRequestResponse(w, r, err)
+			}
+			return
+		}
+	}
+	if input.ExpireAt != nil {
+		u.ExpireAt = *input.ExpireAt
+	}
+	if input.NeverExpires {
+		u.ExpireAt = time.Time{}
+	}
+	if input.ActiveFrom != nil {
+		u.ActiveFrom = *input.ActiveFrom
+	}
+	if input.AlwaysPreview != nil {
+		u.AlwaysPreview = *input.AlwaysPreview
+	}
+	if err := validateActiveTime(u.ActiveFrom, u.ExpireAt); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	err = app.urlModel.Update(u)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeURL(w, r, u)
+}
+
+// deleteURL deletes the shortened URL having the short path id.
+func (app *App) deleteURL(w http.ResponseWriter, r *http.Request, id string) {
+	u, err := app.urlModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !app.canManage(r, u) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err = app.urlModel.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = writeJSON(w, http.StatusOK, envelop{"message": "url successfully deleted"}, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// showStats writes the click statistics of the shortened URL having the short path id into response.
+func (app *App) showStats(w http.ResponseWriter, r *http.Request, id string) {
+	u, err := app.urlModel.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if !app.canManage(r, u) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	stats, err := app.clickModel.Stats(u.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	for i := range stats.Referrers {
+		if stats.Referrers[i].Referrer == "" {
+			stats.Referrers[i].Referrer = directReferrer
+		}
+	}
+
+	data := envelop{
+		"stats": envelop{
+			"id":        u.ShortPath,
+			"total":     stats.Total,
+			"daily":     stats.Daily,
+			"referrers": stats.Referrers,
+		},
+	}
+	err = writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// healthz reports that the server process is up.
+func (app *App) healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	err := writeJSON(w, http.StatusOK, envelop{"status": "available"}, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// readyz reports whether the server is ready to serve requests.
+// The server is not ready when it is shutting down or the database cannot be reached.
+func (app *App) readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	if atomic.LoadInt32(&app.shuttingDown) == 1 {
+		app.notReadyResponse(w, r, "shutting down")
+		return
+	}
+	if app.db != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), app.config.DB.QueryTimeout)
+		defer cancel()
+		if err := app.db.PingContext(ctx); err != nil {
+			app.logError(r, err)
+			app.notReadyResponse(w, r, "database unavailable")
+			return
+		}
 	}
 
-	// Redirect to the origin URL.
-	http.Redirect(w, r, u.URL, http.StatusSeeOther)
+	err := writeJSON(w, http.StatusOK, envelop{"status": "ready"}, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// showMetrics writes the metrics of the application into response in the Prometheus text format.
+func (app *App) showMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	app.metrics.writeTo(w)
+	writeMetric(w, "urlshortener_clicks_dropped_total", "Number of clicks dropped without being recorded.", "counter",
+		float64(app.clicks.droppedClicks()))
+	if app.db != nil {
+		writeDBStats(w, app.db.Stats())
+	}
 }
@@ -1,23 +1,47 @@
 package urlshortener
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strings"
 	"time"
 
+	"github.com/Kerseee/urlshortener/config"
 	"github.com/Kerseee/urlshortener/internal/data"
 )
 
 type envelop map[string]interface{} // wrap the data to be parsed into JSON
 
-var validURLExp = regexp.MustCompile(`^https?:\/\/`)
+var (
+	validHostExp    = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*\.?$`)
+	numericLabelExp = regexp.MustCompile(`(^|\.)(0x[0-9a-f]*|[0-9]+)\.?$`) // last label read as an IPv4 address by browsers
+	validAliasExp   = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+const (
+	minAliasLen = 3  // minimum length of a custom alias
+	maxAliasLen = 64 // maximum length of a custom alias
+)
+
+// reservedPaths are the first path segments used by the application itself,
+// which cannot be used as custom aliases.
+var reservedPaths = map[string]struct{}{
+	"api":     {},
+	"healthz": {},
+	"readyz":  {},
+	"metrics": {},
+	"batch":   {}, // "/api/v1/urls/batch" shadows managing the alias
+}
 
 // writeJson encodes data into JSON, and writes status, encoded data and headers into a response.
 func writeJSON(w http.ResponseWriter, status int, data envelop, headers http.Header) error {
@@ -42,6 +66,31 @@ func writeJSON(w http.ResponseWriter, status int, data envelop, headers http.Hea
 	return err
 }
 
+// writeHTML executes tmpl with data, and writes status, headers and the HTML page into a response.
+// The pages are neither cached nor framed by other sites.
+func (app *App) writeHTML(w http.ResponseWriter, r *http.Request, status int, tmpl *template.Template, data interface{}, headers http.Header) {
+	// Execute the template before writing anything.
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Write headers.
+	for k, v := range headers {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+
+	// Write http status and the page.
+	w.WriteHeader(status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		app.logError(r, err)
+	}
+}
+
 // readJSON reads the JSON-encoded request body with the body size limited to 1MB,
 // decodes the JSON and stores it into a instance pointed to by v.
 func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
@@ -91,12 +140,124 @@ func hashAndEncode(s string) string {
 	return encoded
 }
 
-// validateURL returns error if s is not an URL.
-func validateURL(s string) error {
-	match := validURLExp.MatchString(s)
-	if !match {
+// validateURL returns error if s is not an absolute http or https URL to a public host,
+// or if s points to this service, which would redirect in loops, or to a domain rejected by app.domains.
+//
+// Hosts are checked literally without DNS resolution. Internationalized domains should be in punycode.
+func (app *App) validateURL(s string) error {
+	// Parse the url.
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Opaque != "" {
 		return errors.New("invalid url")
 	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return errors.New("invalid url")
+	}
+	if u.User != nil {
+		return errors.New("url should not contain user info")
+	}
+
+	// Check the host.
+	ip := net.ParseIP(host)
+	switch {
+	case ip != nil:
+		if !publicIP(ip) {
+			return errors.New("url should not point to a loopback or private address")
+		}
+	case !validHostExp.MatchString(host) || numericLabelExp.MatchString(host):
+		return errors.New("invalid url host")
+	case normalizeDomain(host) == "localhost" || strings.HasSuffix(normalizeDomain(host), ".localhost"):
+		return errors.New("url should not point to a loopback or private address")
+	}
+	if own := app.ownHost(); own != "" && normalizeDomain(host) == normalizeDomain(own) {
+		return errors.New("url should not point to this service")
+	}
+	return app.domains.check(host)
+}
+
+// publicIP reports whether ip is a global unicast address outside the private networks.
+func publicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// ownHost returns the host of app.config.Addr, which is either "host:port" or a URL.
+func (app *App) ownHost() string {
+	addr := app.config.Addr
+	if u, err := url.Parse(addr); err == nil && u.Host != "" {
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// validateAlias returns error if s cannot be used as a custom short path.
+func validateAlias(s string) error {
+	if len(s) < minAliasLen || len(s) > maxAliasLen {
+		return fmt.Errorf("alias should be %d to %d characters long", minAliasLen, maxAliasLen)
+	}
+	if !validAliasExp.MatchString(s) {
+		return errors.New("alias should only contain letters, digits, '-' and '_'")
+	}
+	if _, ok := reservedPaths[strings.ToLower(s)]; ok {
+		return fmt.Errorf("alias %q is reserved", s)
+	}
+	return nil
+}
+
+// validateURLInput validates the fields of a url to be shortened and returns the error messages.
+func (app *App) validateURLInput(in *urlInput) []string {
+	var errs []string
+	if err := app.validateURL(in.URL); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if in.ExpireAt != nil {
+		if in.NeverExpires {
+			errs = append(errs, "expireAt and neverExpires should not be both provided")
+		} else if err := validateExpireTime(*in.ExpireAt); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if in.ActiveFrom != nil && in.ExpireAt != nil {
+		if err := validateActiveTime(*in.ActiveFrom, *in.ExpireAt); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if in.Alias != "" {
+		if err := validateAlias(in.Alias); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if in.RedirectCode != 0 {
+		if err := validateRedirectCode(in.RedirectCode); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if in.MaxClicks < 0 {
+		errs = append(errs, "maxClicks should be a positive integer")
+	}
+	if len(in.Password) > maxPasswordLen {
+		errs = append(errs, fmt.Sprintf("password should not be longer than %d bytes", maxPasswordLen))
+	}
+	return errs
+}
+
+// validateRedirectCode returns error if code cannot be used as the status code of redirects.
+func validateRedirectCode(code int) error {
+	if !config.ValidRedirectCode(code) {
+		return fmt.Errorf("redirectCode should be one of %v", config.RedirectCodes)
+	}
+	return nil
+}
+
+// validateActiveTime returns error if the active time activeFrom is not before the expire time expireAt.
+// Zero activeFrom is active once created, and zero expireAt never expires.
+func validateActiveTime(activeFrom, expireAt time.Time) error {
+	if !activeFrom.IsZero() && !expireAt.IsZero() && !activeFrom.Before(expireAt) {
+		return errors.New("activeFrom should be before expireAt")
+	}
 	return nil
 }
 
@@ -108,52 +269,262 @@ func validateExpireTime(t time.Time) error {
 	return nil
 }
 
-// shortenURL shortens s into 8 bytes long string.
-func (app *App) shortenURL(s string) (string, error) {
+// shortenURL shortens u.URL salted with salt into a short path of app.config.ShortURL.Len characters by app.codes.
+func (app *App) shortenURL(u *data.URL, salt string) (string, error) {
 	if app.config.ShortURL.Len <= 0 || app.config.ShortURL.Len > 43 {
 		return "", errors.New("config.ShortURL.Len out of the range [1, 43]")
 	}
-	return hashAndEncode(s)[:app.config.ShortURL.Len], nil
+	return app.codes.generate(u, salt, app.config.ShortURL.Len)
 }
 
-// reShortenUrl re-shortens the URL in u.
+// reShortenUrl re-shortens the URL in u salted with salt.
 //
-// This method is called in registerURL in case of short URL conflict.
-// reShortenURL keep adding 1 character to the short URL and trying to insert into the database.
+// This method is called in createURL in case of short URL conflict.
+// reShortenURL keep generating the short URL with 1 more character and trying to insert into the database.
 // The range of the length of short URLs are from app.config.Short.Len + 1 to app.config.ShortURL.MaxReShortenLen.
-func (app *App) reShortenURL(w http.ResponseWriter, r *http.Request, u *data.URL) {
-	// Hash and encodes the origin URL.
-	encodedURL := hashAndEncode(u.URL)
-
+func (app *App) reShortenURL(u *data.URL, salt string) error {
 	// Try inserting the shortened URL by adding 1 charachter each time.
 	for i := app.config.ShortURL.Len + 1; i <= app.config.ShortURL.MaxReShortenLen; i++ {
-		u.ShortPath = encodedURL[:i]
-		err := app.urlModel.Insert(u)
+		shortPath, err := app.codes.generate(u, salt, i)
+		if err != nil {
+			return err
+		}
+		u.ShortPath = shortPath
+		err = app.urlModel.Insert(u)
 		if err == nil {
-			app.writeShortURL(w, r, u.ShortPath)
-			return
+			app.metrics.shorten(shortenReShortened)
+			return nil
 		}
 		if !errors.Is(err, data.ErrDuplicateShortUrl) {
-			app.serverErrorResponse(w, r, err)
-			return
+			return err
 		}
 	}
-	app.serverErrorResponse(w, r, errors.New("server internal error: short URL conflict"))
+	app.metrics.shorten(shortenConflictExhausted)
+	return errShortURLConflict
 }
 
-// writeShortURL transform the shortPath into a valid short URL and writes the short URL to client.
-func (app *App) writeShortURL(w http.ResponseWriter, r *http.Request, shortPath string) {
+// shortURL transforms the shortPath into a valid short URL.
+func (app *App) shortURL(shortPath string) string {
 	shortURL := &url.URL{
 		Scheme: "http",
 		Host:   app.config.Addr,
 		Path:   shortPath,
 	}
+	return shortURL.String()
+}
+
+// writeShortURL transform the shortPath into a valid short URL and writes the short URL to client.
+func (app *App) writeShortURL(w http.ResponseWriter, r *http.Request, shortPath string) {
 	data := envelop{
 		"id":       shortPath,
-		"shortUrl": shortURL.String(),
+		"shortUrl": app.shortURL(shortPath),
 	}
 	err := writeJSON(w, http.StatusOK, data, nil)
 	if err != nil {
-		app.logError(err)
+		app.logError(r, err)
+	}
+}
+
+// splitURLsPath splits the path of a request to "/api/v1/urls/:id/:sub" into id and sub.
+// sub is empty if the path is "/api/v1/urls/:id".
+func splitURLsPath(path string) (id, sub string) {
+	rest := strings.TrimPrefix(path, urlsPath)
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return rest, ""
+	}
+	return rest[:i], rest[i+1:]
+}
+
+// coarseIP extracts the client IP from remoteAddr ("host:port" or "host")
+// and masks it into its /24 network for IPv4 or /48 network for IPv6.
+// It returns an empty string if remoteAddr does not contain a valid IP.
+func coarseIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// recordClick records a successful redirect of u requested by r in background.
+// Clicks are dropped rather than delaying the redirect if the recorder is overloaded.
+func (app *App) recordClick(r *http.Request, u *data.URL) {
+	c := &data.Click{
+		URLID:     u.ID,
+		ShortPath: u.ShortPath,
+		ClickedAt: time.Now(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		ClientIP:  coarseIP(app.clientIP(r)),
+	}
+	app.clicks.record(c)
+}
+
+// writeURL writes the details of the shortened URL u to client.
+func (app *App) writeURL(w http.ResponseWriter, r *http.Request, u *data.URL) {
+	data := envelop{
+		"url": envelop{
+			"id":              u.ShortPath,
+			"url":             u.URL,
+			"shortUrl":        app.shortURL(u.ShortPath),
+			"expireAt":        timeJSON(u.ExpireAt),
+			"activeFrom":      timeJSON(u.ActiveFrom),
+			"redirectCode":    app.redirectCode(u),
+			"protected":       u.Protected(),
+			"flagged":         u.Flagged,
+			"alwaysPreview":   u.AlwaysPreview,
+			"maxClicks":       maxClicksJSON(u.MaxClicks),
+			"remainingClicks": remainingClicksJSON(u),
+			"createdAt":       timeJSON(u.CreatedAt),
+		},
+	}
+	err := writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// canManage reports whether the client of r is permitted to manage u.
+//
+// A URL having an owner can only be managed with its owner's API key.
+// A URL without owner can only be managed when API keys are not required.
+func (app *App) canManage(r *http.Request, u *data.URL) bool {
+	if u.OwnerID == 0 {
+		return !app.config.Auth.Required
+	}
+	return contextGetOwnerID(r) == u.OwnerID
+}
+
+// clientIP returns the IP of the client of r.
+//
+// If r comes from a trusted proxy in app.config.TrustedProxies, the client IP is the rightmost
+// address in the X-Forwarded-For header which is not a trusted proxy.
+// Otherwise, it is the host of r.RemoteAddr.
+func (app *App) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !app.isTrustedProxy(host) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(addr) == nil {
+			break
+		}
+		host = addr
+		if !app.isTrustedProxy(addr) {
+			break
+		}
+	}
+	return host
+}
+
+// isTrustedProxy reports whether addr is an IP in app.config.TrustedProxies.
+func (app *App) isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range app.config.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectCode returns the HTTP status code redirecting to u.URL.
+func (app *App) redirectCode(u *data.URL) int {
+	if u.RedirectCode == 0 {
+		return app.config.Redirect.DefaultCode
+	}
+	return u.RedirectCode
+}
+
+// preservesMethod reports whether the redirect status code makes the clients preserve the request method.
+func preservesMethod(code int) bool {
+	return code == http.StatusTemporaryRedirect || code == http.StatusPermanentRedirect
+}
+
+// newURL creates the URL to be inserted from in, which is requested by the client of r.
+// The password in in is hashed into the URL.
+func (app *App) newURL(r *http.Request, in *urlInput) (*data.URL, error) {
+	u := &data.URL{
+		URL:           in.URL,
+		ShortPath:     in.Alias,
+		OwnerID:       contextGetOwnerID(r),
+		RedirectCode:  in.RedirectCode,
+		MaxClicks:     in.MaxClicks,
+		AlwaysPreview: in.AlwaysPreview,
+	}
+	if in.ActiveFrom != nil {
+		u.ActiveFrom = *in.ActiveFrom
+	}
+
+	// The default lifetime starts when the URL becomes active.
+	switch start := time.Now(); {
+	case in.NeverExpires:
+	case in.ExpireAt != nil:
+		u.ExpireAt = *in.ExpireAt
+	case app.config.Expire.DefaultTTL > 0:
+		if u.ActiveFrom.After(start) {
+			start = u.ActiveFrom
+		}
+		u.ExpireAt = start.Add(app.config.Expire.DefaultTTL).UTC().Truncate(time.Second)
+	}
+	if in.Password != "" {
+		hash, err := data.HashPassword(in.Password)
+		if err != nil {
+			return nil, err
+		}
+		u.PasswordHash = hash
+	}
+	return u, nil
+}
+
+// maxClicksJSON returns n for encoding into JSON, or nil if n is 0 which is unlimited.
+func maxClicksJSON(n int64) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// remainingClicksJSON returns the number of remaining redirects of u for encoding into JSON,
+// or nil if the redirects of u are unlimited.
+func remainingClicksJSON(u *data.URL) interface{} {
+	if u.MaxClicks == 0 {
+		return nil
+	}
+	if u.ClickCount >= u.MaxClicks {
+		return 0
+	}
+	return u.MaxClicks - u.ClickCount
+}
+
+// exclusive reports whether u always gets its own short path instead of sharing it with the same URL,
+// since its redirects are protected by a password or limited in clicks.
+func exclusive(u *data.URL) bool {
+	return u.Protected() || u.MaxClicks > 0
+}
+
+// timeJSON returns t for encoding into JSON, or nil if t is zero,
+// which never expires for expire times and is active once created for active times.
+func timeJSON(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
 	}
+	return t
 }
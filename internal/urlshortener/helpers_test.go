@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -79,19 +79,129 @@ func TestValidateURL(t *testing.T) {
 	}{
 		{"valid http URL", "http://google.com", ""},
 		{"valid https URL", "https://google.com", ""},
+		{"valid URL with port, path and query", "HTTPS://Docs.Google.com:8443/a/b?c=d#e", ""},
+		{"public IP", "http://8.8.8.8/dns", ""},
 		{"unvalid URL", "http/", "invalid url"},
 		{"non http URL", "ftp://", "invalid url"},
 		{"empty URL", "", "invalid url"},
+		{"no host", "http://", "invalid url"},
+		{"garbage host", "http://<script>", "invalid url host"},
+		{"host with space", "http://goo gle.com", "invalid url"},
+		{"opaque URL", "http:google.com", "invalid url"},
+		{"invalid port", "http://google.com:port", "invalid url"},
+		{"user info", "https://google.com@evil.com", "url should not contain user info"},
+		{"host with underscore", "http://goo_gle.com", "invalid url host"},
+		{"numeric host", "http://2130706433", "invalid url host"},
+		{"hex host", "http://0x7f.1", "invalid url host"},
+		{"localhost", "http://localhost:3000", "url should not point to a loopback or private address"},
+		{"localhost subdomain", "http://app.localhost", "url should not point to a loopback or private address"},
+		{"loopback IP", "http://127.0.0.1/admin", "url should not point to a loopback or private address"},
+		{"private IP", "http://192.168.1.1", "url should not point to a loopback or private address"},
+		{"link-local IP", "http://169.254.169.254/latest/meta-data", "url should not point to a loopback or private address"},
+		{"unspecified IP", "http://0.0.0.0", "url should not point to a loopback or private address"},
+		{"loopback IPv6", "http://[::1]:8080", "url should not point to a loopback or private address"},
+		{"private IPv6", "http://[fd00::1]", "url should not point to a loopback or private address"},
+		{"own domain", "https://sho.rt/BQRvJsg-", "url should not point to this service"},
+		{"own domain with trailing dot", "https://SHO.RT./BQRvJsg-", "url should not point to this service"},
 	}
 
+	app, _ := newTestApp(t)
+	app.config.Addr = "sho.rt:443"
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			err := validateURL(test.url)
+			err := app.validateURL(test.url)
 			switch {
 			case err == nil && test.wantErrMsg != "":
 				t.Errorf(`want error message contains "%v", got nil error`, test.wantErrMsg)
 			case err != nil && test.wantErrMsg == "":
 				t.Errorf(`want nil error, got "%v"`, err)
+			case err != nil && err.Error() != test.wantErrMsg:
+				t.Errorf(`want error message "%v", got "%v"`, test.wantErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		name       string
+		alias      string
+		wantErrMsg string
+	}{
+		{"valid alias", "spring-sale", ""},
+		{"valid alias with underscore", "Spring_Sale_2", ""},
+		{"too short", "ab", "characters long"},
+		{"too long", strings.Repeat("a", 65), "characters long"},
+		{"invalid character", "spring/sale", "should only contain"},
+		{"reserved word", "api", "is reserved"},
+		{"reserved word in upper case", "Api", "is reserved"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateAlias(test.alias)
+			switch {
+			case err == nil && test.wantErrMsg != "":
+				t.Errorf(`want error message contains "%s", got nil error`, test.wantErrMsg)
+			case err != nil && test.wantErrMsg == "":
+				t.Errorf(`want nil error, got "%v"`, err)
+			case err != nil && !strings.Contains(err.Error(), test.wantErrMsg):
+				t.Errorf(`want error message contains "%s", got "%v"`, test.wantErrMsg, err)
+			}
+		})
+	}
+}
+
+func TestCoarseIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{"IPv4 with port", "203.0.113.57:52100", "203.0.113.0"},
+		{"IPv4 without port", "203.0.113.57", "203.0.113.0"},
+		{"IPv6 with port", "[2001:db8:85a3:8d3:1319:8a2e:370:7348]:443", "2001:db8:85a3::"},
+		{"invalid address", "not-an-ip:80", ""},
+		{"empty address", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := coarseIP(test.remoteAddr); got != test.want {
+				t.Errorf("want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct client", "203.0.113.57:52100", nil, "203.0.113.57"},
+		{"untrusted proxy", "203.0.113.57:52100", []string{"198.51.100.1"}, "203.0.113.57"},
+		{"trusted proxy", "10.0.0.1:52100", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed header", "10.0.0.1:52100", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"chained trusted proxies", "10.0.0.1:52100", []string{"198.51.100.1, 10.0.0.2", "10.0.0.3"}, "198.51.100.1"},
+		{"invalid header", "10.0.0.1:52100", []string{"unknown"}, "10.0.0.1"},
+		{"trusted proxy without header", "10.0.0.1:52100", nil, "10.0.0.1"},
+	}
+
+	app, _ := newTestApp(t)
+	app.config.TrustedProxies = []*net.IPNet{proxies}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/BQRvJsg-", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, v := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := app.clientIP(r); got != test.want {
+				t.Errorf("want %q, got %q", test.want, got)
 			}
 		})
 	}
@@ -111,21 +221,21 @@ func TestReadJson(t *testing.T) {
 	}{
 		{
 			name:       "valid request",
-			body:       `{"url":"http://google.com","expireAt":"2023-12-22T12:00:00Z"}`,
+			body:       `{"url":"http://google.com","expireAt":"2033-12-22T12:00:00Z"}`,
 			wantErrMsg: "",
 			wantData: urlBody{
 				Url:      "http://google.com",
-				ExpireAt: time.Date(2023, 12, 22, 12, 0, 0, 0, time.UTC),
+				ExpireAt: time.Date(2033, 12, 22, 12, 0, 0, 0, time.UTC),
 			},
 		},
 		{
 			name:       "invalid json syntax",
-			body:       `{"url":"http://facebook.com"sd,"expireAt":"2023-12-22T12:00:00Z"s}`,
+			body:       `{"url":"http://facebook.com"sd,"expireAt":"2033-12-22T12:00:00Z"s}`,
 			wantErrMsg: "has syntax error at character",
 		},
 		{
 			name:       "invalid json type",
-			body:       `{"url":123, "expireAt":"2023-12-22T12:00:00Z"}`,
+			body:       `{"url":123, "expireAt":"2033-12-22T12:00:00Z"}`,
 			wantErrMsg: "has incorrect type",
 		},
 		{
@@ -140,12 +250,12 @@ func TestReadJson(t *testing.T) {
 		},
 		{
 			name:       "oversize body",
-			body:       fmt.Sprintf(`{"url":"http://google.com/%s","expireAt":"2023-12-22T12:00:00Z"}`, strings.Repeat("a", 1<<21)),
+			body:       fmt.Sprintf(`{"url":"http://google.com/%s","expireAt":"2033-12-22T12:00:00Z"}`, strings.Repeat("a", 1<<21)),
 			wantErrMsg: "body size should not exceed 1 MB",
 		},
 		{
 			name:       "two json",
-			body:       `{"url":"https://youtube.com","expireAt":"2023-12-22T12:00:00Z"}{"url":"https://youtube.com","expireAt":"2023-12-22T12:00:00Z"}`,
+			body:       `{"url":"https://youtube.com","expireAt":"2033-12-22T12:00:00Z"}{"url":"https://youtube.com","expireAt":"2033-12-22T12:00:00Z"}`,
 			wantErrMsg: "more than 1 JSON in the request",
 		},
 	}
@@ -219,7 +329,7 @@ func TestValidateExpireTime(t *testing.T) {
 		},
 		{
 			name:       "after now local",
-			time:       time.Date(2023, 12, 22, 12, 0, 0, 0, time.Local),
+			time:       time.Date(2033, 12, 22, 12, 0, 0, 0, time.Local),
 			wantErrMsg: "",
 		},
 		{
@@ -229,7 +339,7 @@ func TestValidateExpireTime(t *testing.T) {
 		},
 		{
 			name:       "after now local",
-			time:       time.Date(2023, 12, 22, 12, 0, 0, 0, time.UTC),
+			time:       time.Date(2033, 12, 22, 12, 0, 0, 0, time.UTC),
 			wantErrMsg: "",
 		},
 		{
@@ -264,6 +374,31 @@ func TestValidateExpireTime(t *testing.T) {
 	}
 }
 
+func TestNewURLDefaultTTL(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.config.Expire.DefaultTTL = 24 * time.Hour
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/urls", nil)
+
+	// The default lifetime starts from now.
+	u, err := app.newURL(r, &urlInput{URL: "https://google.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(u.ExpireAt); d <= 23*time.Hour || d > 24*time.Hour {
+		t.Errorf("want expire time about 24 hours from now, got %v", u.ExpireAt)
+	}
+
+	// The default lifetime of a scheduled url starts from its active time.
+	activeFrom := time.Date(2033, time.December, 22, 12, 0, 0, 0, time.UTC)
+	u, err = app.newURL(r, &urlInput{URL: "https://google.com", ActiveFrom: &activeFrom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := activeFrom.Add(24 * time.Hour); !u.ExpireAt.Equal(want) || !u.ActiveFrom.Equal(activeFrom) {
+		t.Errorf("want active from %v until %v, got %v until %v", activeFrom, want, u.ActiveFrom, u.ExpireAt)
+	}
+}
+
 func TestShortenURL(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -297,11 +432,11 @@ func TestShortenURL(t *testing.T) {
 		},
 	}
 
-	app, _ := newTestApp()
+	app, _ := newTestApp(t)
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			app.config.ShortURL.Len = test.lenShortURL
-			shortURL, err := app.shortenURL(test.url)
+			shortURL, err := app.shortenURL(&data.URL{URL: test.url}, "")
 			switch {
 			case err == nil && test.wantErrMsg == "":
 				if len(shortURL) != test.lenShortURL {
@@ -320,58 +455,38 @@ func TestShortenURL(t *testing.T) {
 
 func TestReShortenURL(t *testing.T) {
 	tests := []struct {
-		name     string
-		u        *data.URL
-		wantCode int
-		wantBody []string
-		wantLog  string
+		name        string
+		u           *data.URL
+		wantPathLen int
+		wantErr     error
 	}{
 		{
 			name: "valid url",
 			u: &data.URL{
 				URL:      "https://facebook.com",
-				ExpireAt: time.Date(2025, 12, 22, 12, 0, 0, 0, time.UTC),
+				ExpireAt: time.Date(2035, 12, 22, 12, 0, 0, 0, time.UTC),
 			},
-			wantCode: http.StatusOK,
-			wantBody: []string{"id", "shortUrl", "localhost:8080"},
+			wantPathLen: 9,
 		},
 		{
 			name: "conflict url",
 			u: &data.URL{
 				URL:      "https://netflix.com",
-				ExpireAt: time.Date(2025, 12, 22, 12, 0, 0, 0, time.UTC),
+				ExpireAt: time.Date(2035, 12, 22, 12, 0, 0, 0, time.UTC),
 			},
-			wantCode: http.StatusInternalServerError,
-			wantBody: []string{"error", "server cannot process your request now"},
-			wantLog:  "short URL conflict",
+			wantErr: errShortURLConflict,
 		},
 	}
 
-	app, logger := newTestApp()
-	wantHeader := http.Header{"Content-Type": []string{"application/json"}}
+	app, _ := newTestApp(t)
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			// Send a request.
-			r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/urls", nil)
-			w := httptest.NewRecorder()
-			app.reShortenURL(w, r, test.u)
-
-			// Extract the response.
-			code, header, body := getResponse(t, w)
-
-			// Validate the response.
-			validateCode(t, test.wantCode, code)
-			validateHeader(t, wantHeader, header)
-			for _, wantBody := range test.wantBody {
-				validateBodyContains(t, wantBody, string(body))
+			err := app.reShortenURL(test.u, "")
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("want error %v, got %v", test.wantErr, err)
 			}
-
-			if test.wantLog != "" {
-				logMsg, err := io.ReadAll(logger)
-				if err != nil {
-					t.Fatal(err)
-				}
-				validateBodyContains(t, test.wantLog, string(logMsg))
+			if err == nil && len(test.u.ShortPath) != test.wantPathLen {
+				t.Errorf("want %d-byte-long short path, got %q", test.wantPathLen, test.u.ShortPath)
 			}
 		})
 	}
@@ -392,7 +507,7 @@ func TestWriteShortURL(t *testing.T) {
 	// Send a request
 	r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/urls", nil)
 	w := httptest.NewRecorder()
-	app, _ := newTestApp()
+	app, _ := newTestApp(t)
 	app.writeShortURL(w, r, path)
 
 	// Extract the response.
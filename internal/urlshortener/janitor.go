@@ -0,0 +1,65 @@
+package urlshortener
+
+import (
+	"context"
+	"time"
+)
+
+// PurgeExpired deletes the shortened URLs expired longer than app.config.Purge.Retention
+// in batches of app.config.Purge.BatchSize until none is left or ctx is done,
+// and returns the number of deleted URLs.
+func (app *App) PurgeExpired(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-app.config.Purge.Retention)
+	var total int64
+	for ctx.Err() == nil {
+		n, err := app.urlModel.DeleteExpired(before, app.config.Purge.BatchSize)
+		total += n
+		app.metrics.purge(n)
+		if err != nil {
+			return total, err
+		}
+		if n < int64(app.config.Purge.BatchSize) {
+			break
+		}
+	}
+	return total, nil
+}
+
+// startJanitor runs runJanitor in background until ctx is done or the returned stop function is called.
+// stop waits for the running purge. The janitor is not run if app.config.Purge.Interval is 0.
+func (app *App) startJanitor(ctx context.Context) (stop func()) {
+	if app.config.Purge.Interval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.runJanitor(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// runJanitor purges the expired shortened URLs every app.config.Purge.Interval until ctx is done.
+func (app *App) runJanitor(ctx context.Context) {
+	ticker := time.NewTicker(app.config.Purge.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := app.PurgeExpired(ctx)
+			if err != nil {
+				app.logError(nil, err)
+			}
+			if n > 0 {
+				app.logInfo("Purged expired urls", "purged_urls", n)
+			}
+		}
+	}
+}
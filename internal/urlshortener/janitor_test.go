@@ -0,0 +1,65 @@
+package urlshortener
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// newTestPurgeApp returns a test App backed by a data.MemoryStore holding n URLs expired beyond the retention
+// and 1 URL expired within the retention.
+func newTestPurgeApp(t *testing.T, n int) (*App, *data.MemoryStore) {
+	app, _ := newTestApp(t)
+	store := data.NewMemoryStore()
+	app.urlModel = store
+	app.config.Purge.Retention = time.Hour
+	app.config.Purge.BatchSize = 2
+
+	for i := 0; i < n; i++ {
+		u := &data.URL{URL: "https://github.com", ShortPath: string(rune('a'+i)) + "xpired", ExpireAt: time.Now().Add(-2 * time.Hour)}
+		if err := store.Insert(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Insert(&data.URL{URL: "https://github.com", ShortPath: "recently-expired", ExpireAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	return app, store
+}
+
+func TestPurgeExpired(t *testing.T) {
+	app, store := newTestPurgeApp(t, 5)
+
+	n, err := app.PurgeExpired(context.Background())
+	if err != nil || n != 5 {
+		t.Fatalf("want 5 purged, got %d, %v", n, err)
+	}
+	if _, err := store.Get("recently-expired"); err != nil {
+		t.Errorf("want URL expired within retention kept, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	app.metrics.writeTo(&buf)
+	validateBodyContains(t, "urlshortener_purged_urls_total 5\n", buf.String())
+}
+
+func TestJanitor(t *testing.T) {
+	app, store := newTestPurgeApp(t, 3)
+	app.config.Purge.Interval = 10 * time.Millisecond
+	stop := app.startJanitor(context.Background())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := store.Get("axpired"); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("want expired URLs purged in background, got not purged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+}
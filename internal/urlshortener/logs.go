@@ -1,11 +1,78 @@
 package urlshortener
 
-// logError logs err via app.Logger.
-func (app *App) logError(err error) {
-	app.logger.Printf("[ERROR] %v", err.Error())
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Formats of the log.
+const (
+	logFormatJSON = "json"
+	logFormatText = "text"
+)
+
+// newLogger creates a leveled structured logger writing to w.
+// format is either "json" or "text", and level is one of "debug", "info", "warn" and "error".
+// Invalid format and level fall back to "json" and "info".
+func newLogger(w io.Writer, format, level string) *slog.Logger {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		l = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	if strings.EqualFold(format, logFormatText) {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// A requestLog collects the errors occurred while serving a request,
+// which are logged together with the access log of the request.
+type requestLog struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// add appends err to l.
+func (l *requestLog) add(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, err)
+}
+
+// err returns the collected errors joined, or nil.
+func (l *requestLog) err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return errors.Join(l.errs...)
+}
+
+// logError logs err occurred while serving r.
+//
+// If r is served through app.instrument, err is logged in the access log of r,
+// which carries the request ID, route, status and latency. Otherwise err is logged immediately
+// with the request ID and route of r. r is nil for errors occurred outside of requests.
+func (app *App) logError(r *http.Request, err error) {
+	if r == nil {
+		app.logger.Error(err.Error())
+		return
+	}
+	if l := contextGetRequestLog(r); l != nil {
+		l.add(err)
+		return
+	}
+	app.logger.Error(err.Error(),
+		"request_id", contextGetRequestID(r),
+		"method", r.Method,
+		"route", routeOf(r.URL.Path),
+	)
 }
 
-// logInfo logs msg via app.Logger
-func (app *App) logInfo(msg string) {
-	app.logger.Printf("[INFO] %s", msg)
+// logInfo logs msg with the key-value pairs in args.
+func (app *App) logInfo(msg string, args ...interface{}) {
+	app.logger.Info(msg, args...)
 }
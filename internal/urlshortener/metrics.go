@@ -0,0 +1,252 @@
+package urlshortener
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the buckets of the request latency histogram (seconds).
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Outcomes of redirects.
+const (
+	redirectHit       = "hit"       // redirected to the origin URL
+	redirectMiss      = "miss"      // short path not found
+	redirectExpired   = "expired"   // short path found but expired
+	redirectDenied    = "denied"    // wrong password submitted for a password-protected short path
+	redirectExhausted = "exhausted" // short path found but its click limit is reached
+	redirectPending   = "pending"   // short path found but not active yet
+	redirectWarned    = "warned"    // warning shown before redirecting to a flagged origin URL
+	redirectPreviewed = "previewed" // preview page shown instead of redirecting
+)
+
+// Outcomes of shortening URLs.
+const (
+	shortenNew               = "new"                // a new short path is inserted
+	shortenDuplicate         = "duplicate"          // the URL has already been shortened into the same short path
+	shortenReShortened       = "reshortened"        // a longer short path is inserted because of a conflict
+	shortenConflictExhausted = "conflict_exhausted" // no short path is available up to config.ShortURL.MaxReShortenLen
+	shortenAliasConflict     = "alias_conflict"     // the requested alias is used by an unexpired URL
+)
+
+// metrics collects the metrics of the application and exposes them in the Prometheus text format.
+// It is safe for concurrent use.
+type metrics struct {
+	mu        sync.Mutex
+	requests  map[requestLabels]*histogram
+	redirects map[string]uint64 // outcome -> count
+	shortens  map[string]uint64 // outcome -> count
+	purged    uint64            // number of purged expired URLs
+}
+
+// requestLabels are the labels of the request metrics.
+type requestLabels struct {
+	route string
+	code  int
+}
+
+// A histogram counts observations into latencyBuckets.
+type histogram struct {
+	buckets []uint64 // buckets[i] counts the observations <= latencyBuckets[i] and > latencyBuckets[i-1]
+	count   uint64
+	sum     float64
+}
+
+// newMetrics creates an empty metrics.
+func newMetrics() *metrics {
+	return &metrics{
+		requests:  make(map[requestLabels]*histogram),
+		redirects: make(map[string]uint64),
+		shortens:  make(map[string]uint64),
+	}
+}
+
+// observeRequest records a request to route responded with code in d.
+func (m *metrics) observeRequest(route string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := requestLabels{route: route, code: code}
+	h, ok := m.requests[labels]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+		m.requests[labels] = h
+	}
+	seconds := d.Seconds()
+	h.count++
+	h.sum += seconds
+	if i := sort.SearchFloat64s(latencyBuckets, seconds); i < len(latencyBuckets) {
+		h.buckets[i]++
+	}
+}
+
+// redirect records a redirect with outcome.
+func (m *metrics) redirect(outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.redirects[outcome]++
+}
+
+// shorten records a URL shortening with outcome.
+func (m *metrics) shorten(outcome string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shortens[outcome]++
+}
+
+// purge records n purged expired URLs.
+func (m *metrics) purge(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purged += uint64(n)
+}
+
+// writeTo writes the collected metrics into w in the Prometheus text format.
+func (m *metrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Sort the request labels for a stable output.
+	labels := make([]requestLabels, 0, len(m.requests))
+	for l := range m.requests {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].route != labels[j].route {
+			return labels[i].route < labels[j].route
+		}
+		return labels[i].code < labels[j].code
+	})
+
+	writeHeader(w, "urlshortener_http_requests_total", "Number of HTTP requests by route and status code.", "counter")
+	for _, l := range labels {
+		fmt.Fprintf(w, "urlshortener_http_requests_total{route=%q,code=\"%d\"} %d\n", l.route, l.code, m.requests[l].count)
+	}
+
+	writeHeader(w, "urlshortener_http_request_duration_seconds", "Latency of HTTP requests by route and status code.", "histogram")
+	for _, l := range labels {
+		h := m.requests[l]
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(w, "urlshortener_http_request_duration_seconds_bucket{route=%q,code=\"%d\",le=%q} %d\n",
+				l.route, l.code, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(w, "urlshortener_http_request_duration_seconds_bucket{route=%q,code=\"%d\",le=\"+Inf\"} %d\n", l.route, l.code, h.count)
+		fmt.Fprintf(w, "urlshortener_http_request_duration_seconds_sum{route=%q,code=\"%d\"} %s\n", l.route, l.code, formatFloat(h.sum))
+		fmt.Fprintf(w, "urlshortener_http_request_duration_seconds_count{route=%q,code=\"%d\"} %d\n", l.route, l.code, h.count)
+	}
+
+	writeHeader(w, "urlshortener_redirects_total", "Number of redirects by outcome.", "counter")
+	writeOutcomes(w, "urlshortener_redirects_total", m.redirects)
+
+	writeHeader(w, "urlshortener_shortens_total", "Number of URL shortenings by outcome.", "counter")
+	writeOutcomes(w, "urlshortener_shortens_total", m.shortens)
+
+	writeMetric(w, "urlshortener_purged_urls_total", "Number of expired URLs purged.", "counter", float64(m.purged))
+}
+
+// writeDBStats writes the statistics of the database connection pool into w in the Prometheus text format.
+func writeDBStats(w io.Writer, stats sql.DBStats) {
+	writeMetric(w, "urlshortener_db_max_open_connections", "Maximum number of open connections to the database.", "gauge", float64(stats.MaxOpenConnections))
+	writeMetric(w, "urlshortener_db_open_connections", "Number of established connections to the database.", "gauge", float64(stats.OpenConnections))
+	writeMetric(w, "urlshortener_db_in_use_connections", "Number of connections currently in use.", "gauge", float64(stats.InUse))
+	writeMetric(w, "urlshortener_db_idle_connections", "Number of idle connections.", "gauge", float64(stats.Idle))
+	writeMetric(w, "urlshortener_db_wait_count_total", "Total number of connections waited for.", "counter", float64(stats.WaitCount))
+	writeMetric(w, "urlshortener_db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", "counter", stats.WaitDuration.Seconds())
+	writeMetric(w, "urlshortener_db_max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns.", "counter", float64(stats.MaxIdleClosed))
+	writeMetric(w, "urlshortener_db_max_idle_time_closed_total", "Total number of connections closed due to SetConnMaxIdleTime.", "counter", float64(stats.MaxIdleTimeClosed))
+	writeMetric(w, "urlshortener_db_max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime.", "counter", float64(stats.MaxLifetimeClosed))
+}
+
+// writeHeader writes the HELP and TYPE lines of the metric name into w.
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeMetric writes the metric name without labels into w.
+func writeMetric(w io.Writer, name, help, typ string, value float64) {
+	writeHeader(w, name, help, typ)
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+}
+
+// writeOutcomes writes the metric name labeled by the outcomes in counts into w.
+func writeOutcomes(w io.Writer, name string, counts map[string]uint64) {
+	outcomes := make([]string, 0, len(counts))
+	for o := range counts {
+		outcomes = append(outcomes, o)
+	}
+	sort.Strings(outcomes)
+	for _, o := range outcomes {
+		fmt.Fprintf(w, "%s{outcome=%q} %d\n", name, o, counts[o])
+	}
+}
+
+// formatFloat formats v for the Prometheus text format.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// A statusRecorder records the status code written to the embedded http.ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+// WriteHeader records code and writes it to the embedded http.ResponseWriter.
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes b to the embedded http.ResponseWriter, recording status 200 if no status is written.
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// status returns the recorded status code.
+func (rec *statusRecorder) status() int {
+	if rec.code == 0 {
+		return http.StatusOK
+	}
+	return rec.code
+}
+
+// routeOf returns the route pattern of path, which is used as the route label of the request metrics.
+func routeOf(path string) string {
+	switch {
+	case path == "/api/v1/urls":
+		return "/api/v1/urls"
+	case path == batchPath:
+		return batchPath
+	case strings.HasPrefix(path, urlsPath):
+		switch _, sub := splitURLsPath(path); sub {
+		case "":
+			return "/api/v1/urls/:id"
+		case "stats":
+			return "/api/v1/urls/:id/stats"
+		default:
+			return "/api/v1/urls/:id/*"
+		}
+	case strings.HasPrefix(path, "/api/"):
+		return "/api/*"
+	case path == "/healthz", path == "/readyz", path == "/metrics":
+		return path
+	case path == "/":
+		return "/"
+	default:
+		return "/:shortPath"
+	}
+}
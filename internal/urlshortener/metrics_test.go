@@ -0,0 +1,122 @@
+package urlshortener
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouteOf(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/v1/urls", "/api/v1/urls"},
+		{"/api/v1/urls/batch", "/api/v1/urls/batch"},
+		{"/api/v1/urls/BQRvJsg-", "/api/v1/urls/:id"},
+		{"/api/v1/urls/BQRvJsg-/stats", "/api/v1/urls/:id/stats"},
+		{"/api/v1/urls/BQRvJsg-/foo", "/api/v1/urls/:id/*"},
+		{"/api/v2/foo", "/api/*"},
+		{"/healthz", "/healthz"},
+		{"/readyz", "/readyz"},
+		{"/metrics", "/metrics"},
+		{"/", "/"},
+		{"/BQRvJsg-", "/:shortPath"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if got := routeOf(test.path); got != test.want {
+				t.Errorf("want route %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestMetricsWriteTo(t *testing.T) {
+	m := newMetrics()
+	m.observeRequest("/:shortPath", http.StatusSeeOther, 3*time.Millisecond)
+	m.observeRequest("/:shortPath", http.StatusSeeOther, 20*time.Second)
+	m.observeRequest("/api/v1/urls", http.StatusOK, 30*time.Millisecond)
+	m.redirect(redirectHit)
+	m.redirect(redirectHit)
+	m.redirect(redirectExpired)
+	m.shorten(shortenReShortened)
+
+	var buf bytes.Buffer
+	m.writeTo(&buf)
+	got := buf.String()
+	for _, want := range []string{
+		"# TYPE urlshortener_http_requests_total counter\n",
+		`urlshortener_http_requests_total{route="/:shortPath",code="303"} 2` + "\n",
+		`urlshortener_http_requests_total{route="/api/v1/urls",code="200"} 1` + "\n",
+		"# TYPE urlshortener_http_request_duration_seconds histogram\n",
+		`urlshortener_http_request_duration_seconds_bucket{route="/:shortPath",code="303",le="0.001"} 0` + "\n",
+		`urlshortener_http_request_duration_seconds_bucket{route="/:shortPath",code="303",le="0.005"} 1` + "\n",
+		`urlshortener_http_request_duration_seconds_bucket{route="/:shortPath",code="303",le="10"} 1` + "\n",
+		`urlshortener_http_request_duration_seconds_bucket{route="/:shortPath",code="303",le="+Inf"} 2` + "\n",
+		`urlshortener_http_request_duration_seconds_sum{route="/:shortPath",code="303"} 20.003` + "\n",
+		`urlshortener_http_request_duration_seconds_count{route="/:shortPath",code="303"} 2` + "\n",
+		`urlshortener_redirects_total{outcome="expired"} 1` + "\n",
+		`urlshortener_redirects_total{outcome="hit"} 2` + "\n",
+		`urlshortener_shortens_total{outcome="reshortened"} 1` + "\n",
+	} {
+		validateBodyContains(t, want, got)
+	}
+}
+
+func TestWriteDBStats(t *testing.T) {
+	var buf bytes.Buffer
+	writeDBStats(&buf, sql.DBStats{MaxOpenConnections: 25, OpenConnections: 3, InUse: 1, Idle: 2, WaitDuration: 1500 * time.Millisecond})
+	for _, want := range []string{
+		"urlshortener_db_max_open_connections 25\n",
+		"urlshortener_db_open_connections 3\n",
+		"urlshortener_db_in_use_connections 1\n",
+		"urlshortener_db_idle_connections 2\n",
+		"urlshortener_db_wait_duration_seconds_total 1.5\n",
+	} {
+		validateBodyContains(t, want, buf.String())
+	}
+}
+
+func TestShowMetrics(t *testing.T) {
+	app, _ := newTestApp(t)
+	handler := app.routes()
+
+	// Send requests through the instrumented routes.
+	for _, path := range []string{"/BQRvJsg-", "/FGeTGg6M", "/abcd1236"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080"+path, nil))
+	}
+	w := httptest.NewRecorder()
+	body := bytes.NewBufferString(`{"url":"https://facebook.com", "expireAt":"2033-12-22T12:00:00Z"}`)
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/urls", body))
+
+	// Scrape the metrics.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080/metrics", nil))
+	code, header, got := getResponse(t, w)
+	validateCode(t, http.StatusOK, code)
+	validateHeader(t, http.Header{"Content-Type": []string{"text/plain; version=0.0.4; charset=utf-8"}}, header)
+	for _, want := range []string{
+		`urlshortener_http_requests_total{route="/:shortPath",code="303"} 1`,
+		`urlshortener_http_requests_total{route="/:shortPath",code="404"} 2`,
+		`urlshortener_http_requests_total{route="/api/v1/urls",code="200"} 1`,
+		`urlshortener_redirects_total{outcome="hit"} 1`,
+		`urlshortener_redirects_total{outcome="expired"} 1`,
+		`urlshortener_redirects_total{outcome="miss"} 1`,
+		`urlshortener_shortens_total{outcome="new"} 1`,
+		"urlshortener_clicks_dropped_total 0",
+	} {
+		validateBodyContains(t, want, string(got))
+	}
+
+	// Invalid method.
+	w = httptest.NewRecorder()
+	app.showMetrics(w, httptest.NewRequest(http.MethodPost, "http://localhost:8080/metrics", nil))
+	code, _, _ = getResponse(t, w)
+	validateCode(t, http.StatusMethodNotAllowed, code)
+}
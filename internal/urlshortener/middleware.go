@@ -0,0 +1,140 @@
+package urlshortener
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// requestIDHeader is the header carrying the request ID.
+const requestIDHeader = "X-Request-ID"
+
+// validRequestIDExp matches the request IDs from clients that are honoured.
+var validRequestIDExp = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestID honours the X-Request-ID header of the request if it is valid, or generates a new request ID.
+// The request ID is stored in the request context and echoed in the X-Request-ID header of the response.
+func (app *App) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestIDExp.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, contextSetRequestID(r, id))
+	})
+}
+
+// newRequestID generates a random 32-character hexadecimal request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// instrument records the route, status code and latency of every request into app.metrics,
+// and writes an access log line for every request including the errors occurred while serving it.
+func (app *App) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		l := &requestLog{}
+		next.ServeHTTP(rec, contextSetRequestLog(r, l))
+		latency := time.Since(start)
+
+		route := routeOf(r.URL.Path)
+		app.metrics.observeRequest(route, rec.status(), latency)
+
+		level, msg := slog.LevelInfo, "request"
+		args := []interface{}{
+			"request_id", contextGetRequestID(r),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", rec.status(),
+			"latency_ms", float64(latency.Microseconds()) / 1000,
+			"remote_addr", r.RemoteAddr,
+		}
+		if err := l.err(); err != nil {
+			level, msg = slog.LevelError, err.Error()
+		}
+		app.logger.Log(r.Context(), level, msg, args...)
+	})
+}
+
+// authenticate authenticates the request by the API key in the "Authorization: Bearer <key>" header,
+// and stores the API key in the request context.
+//
+// Requests with an invalid API key are rejected.
+// Requests without API key are rejected if app.config.Auth.Required is true.
+func (app *App) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			if app.config.Auth.Required {
+				app.authenticationRequiredResponse(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Extract the API key.
+		parts := strings.SplitN(header, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+			app.invalidAPIKeyResponse(w, r)
+			return
+		}
+
+		// Look up the API key.
+		k, err := app.apiKeyModel.GetByHash(data.HashAPIKey(parts[1]))
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAPIKeyResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, contextSetAPIKey(r, k))
+	})
+}
+
+// rateLimit rejects the requests exceeding the rate limited by l.
+// Requests are limited per API key if authenticated, or per client IP otherwise.
+// It returns next directly if l is nil.
+func (app *App) rateLimit(l *rateLimiter, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.allow(app.rateLimitKey(r)); !ok {
+			app.rateLimitExceededResponse(w, r, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKey returns the key of the client of r in the rate limiters,
+// which is the API key if authenticated, or the client IP otherwise.
+func (app *App) rateLimitKey(r *http.Request) string {
+	if id := contextGetOwnerID(r); id != 0 {
+		return "key:" + strconv.FormatInt(id, 10)
+	}
+	return "ip:" + app.clientIP(r)
+}
@@ -0,0 +1,257 @@
+package urlshortener
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data/mock"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{"honour valid request ID", "abc-123.DEF_456", true},
+		{"generate request ID", "", false},
+		{"replace invalid request ID", "invalid request id", false},
+		{"replace oversize request ID", strings.Repeat("a", 129), false},
+	}
+
+	app, _ := newTestApp(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotCtx string
+			handler := app.requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotCtx = contextGetRequestID(r)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/BQRvJsg-", nil)
+			if test.header != "" {
+				r.Header.Set(requestIDHeader, test.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			got := w.Header().Get(requestIDHeader)
+			if got != gotCtx {
+				t.Errorf("want request ID %q in context, got %q", got, gotCtx)
+			}
+			switch {
+			case test.wantSame && got != test.header:
+				t.Errorf("want request ID %q echoed, got %q", test.header, got)
+			case !test.wantSame && (got == test.header || len(got) != 32):
+				t.Errorf("want a generated 32-character request ID, got %q", got)
+			}
+		})
+	}
+}
+
+func TestInstrumentAccessLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   func(app *App) http.HandlerFunc
+		wantLevel string
+		wantMsg   string
+		wantCode  int
+	}{
+		{
+			name: "access log",
+			handler: func(app *App) http.HandlerFunc {
+				return app.redirect
+			},
+			wantLevel: "INFO",
+			wantMsg:   "request",
+			wantCode:  http.StatusSeeOther,
+		},
+		{
+			name: "error log",
+			handler: func(app *App) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					app.serverErrorResponse(w, r, errors.New("some internal server error"))
+				}
+			},
+			wantLevel: "ERROR",
+			wantMsg:   "some internal server error",
+			wantCode:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, logger := newTestApp(t)
+			handler := app.requestID(app.instrument(test.handler(app)))
+			r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/BQRvJsg-", nil)
+			r.Header.Set(requestIDHeader, "req-1")
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			// Exactly one log line is written for the request.
+			lines := strings.Split(strings.TrimSpace(logger.String()), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("want 1 log line, got %d: %q", len(lines), logger.String())
+			}
+			var entry struct {
+				Level     string  `json:"level"`
+				Msg       string  `json:"msg"`
+				RequestID string  `json:"request_id"`
+				Route     string  `json:"route"`
+				Status    int     `json:"status"`
+				Latency   float64 `json:"latency_ms"`
+			}
+			if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+				t.Fatal(err)
+			}
+			if entry.Level != test.wantLevel || entry.Msg != test.wantMsg {
+				t.Errorf("want %s %q, got %s %q", test.wantLevel, test.wantMsg, entry.Level, entry.Msg)
+			}
+			if entry.RequestID != "req-1" || entry.Route != "/:shortPath" || entry.Status != test.wantCode {
+				t.Errorf(`want request_id "req-1", route "/:shortPath" and status %d, got %+v`, test.wantCode, entry)
+			}
+			if entry.Latency < 0 {
+				t.Errorf("want non-negative latency, got %v", entry.Latency)
+			}
+		})
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		level    string
+		wantLogs []string
+		wantMiss []string
+	}{
+		{"json info", "json", "info", []string{`"msg":"info message"`}, []string{"debug message"}},
+		{"text debug", "text", "debug", []string{"msg=\"debug message\"", "msg=\"info message\""}, nil},
+		{"invalid level", "json", "verbose", []string{"info message"}, []string{"debug message"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf strings.Builder
+			logger := newLogger(&buf, test.format, test.level)
+			logger.Debug("debug message")
+			logger.Info("info message")
+			for _, want := range test.wantLogs {
+				validateBodyContains(t, want, buf.String())
+			}
+			for _, miss := range test.wantMiss {
+				if strings.Contains(buf.String(), miss) {
+					t.Errorf("want log without %q, got %q", miss, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name        string
+		required    bool
+		header      string
+		wantCode    int
+		wantOwnerID int64
+	}{
+		{"valid api key", true, "Bearer " + mock.APIKey1, http.StatusOK, 1},
+		{"case-insensitive scheme", true, "bearer " + mock.APIKey2, http.StatusOK, 2},
+		{"missing api key", true, "", http.StatusUnauthorized, 0},
+		{"missing api key not required", false, "", http.StatusOK, 0},
+		{"invalid api key", false, "Bearer not-a-key", http.StatusUnauthorized, 0},
+		{"invalid scheme", false, "Basic " + mock.APIKey1, http.StatusUnauthorized, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, _ := newTestApp(t)
+			app.config.Auth.Required = test.required
+			var gotOwnerID int64
+			handler := app.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotOwnerID = contextGetOwnerID(r)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/v1/urls/BQRvJsg-", nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			code, header, _ := getResponse(t, w)
+			validateCode(t, test.wantCode, code)
+			if code == http.StatusUnauthorized {
+				validateHeader(t, http.Header{"Www-Authenticate": []string{"Bearer"}}, header)
+			}
+			if gotOwnerID != test.wantOwnerID {
+				t.Errorf("want owner id %d, got %d", test.wantOwnerID, gotOwnerID)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	app, _ := newTestApp(t)
+	l, _ := newTestRateLimiter(1, 2)
+	handler := app.authenticate(app.rateLimit(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	send := func(remoteAddr, apiKey string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/urls", nil)
+		r.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			r.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// The burst of a client IP is allowed, and the next request is rejected.
+	for i := 0; i < 2; i++ {
+		validateCode(t, http.StatusOK, send("203.0.113.57:1234", "").Code)
+	}
+	code, header, body := getResponse(t, send("203.0.113.57:5678", ""))
+	validateCode(t, http.StatusTooManyRequests, code)
+	validateHeader(t, http.Header{"Retry-After": []string{"1"}, "Content-Type": []string{"application/json"}}, header)
+	validateBodyContains(t, "rate limit exceeded", string(body))
+
+	// Authenticated requests from the same IP are limited per API key.
+	validateCode(t, http.StatusOK, send("203.0.113.57:1234", mock.APIKey1).Code)
+	validateCode(t, http.StatusOK, send("203.0.113.58:1234", mock.APIKey1).Code)
+	validateCode(t, http.StatusTooManyRequests, send("203.0.113.59:1234", mock.APIKey1).Code)
+
+	// Other client IPs are not limited.
+	validateCode(t, http.StatusOK, send("198.51.100.1:1234", "").Code)
+}
+
+func TestRateLimitBatch(t *testing.T) {
+	app, _ := newTestApp(t)
+	l, now := newTestRateLimiter(1, 3)
+	app.createLimiter = l
+	handler := app.rateLimit(l, http.HandlerFunc(app.registerURLs))
+	send := func(n int) *httptest.ResponseRecorder {
+		body := "[" + strings.TrimSuffix(strings.Repeat(`{"url":"https://facebook.com"},`, n), ",") + "]"
+		r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/urls/batch", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// A batch takes a token for every url.
+	validateCode(t, http.StatusOK, send(2).Code)
+
+	// A batch exceeding the tokens left is rejected without taking any token.
+	w := send(2)
+	validateCode(t, http.StatusTooManyRequests, w.Code)
+	validateHeader(t, http.Header{"Retry-After": []string{"1"}}, w.Header())
+	validateCode(t, http.StatusOK, send(1).Code)
+
+	// A batch exceeding the burst is never allowed.
+	*now = now.Add(time.Minute)
+	code, _, body := getResponse(t, send(4))
+	validateCode(t, http.StatusBadRequest, code)
+	validateBodyContains(t, "batch should contain at most 3 urls under the rate limit", string(body))
+	validateCode(t, http.StatusOK, send(3).Code)
+}
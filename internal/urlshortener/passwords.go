@@ -0,0 +1,98 @@
+package urlshortener
+
+import (
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+const (
+	maxPasswordLen      = 72      // maximum length of the password of a protected URL, limited by bcrypt
+	maxPasswordFormBody = 4 << 10 // 4KB
+)
+
+// passwordFormTmpl is the HTML page asking for the password of a protected URL.
+// The page posts the password back to the short path.
+var passwordFormTmpl = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<h1>Password required</h1>
+<p>This link is protected by a password.</p>
+{{with .Error}}<p role="alert"><strong>{{.}}</strong></p>{{end}}
+<form method="post" action="/{{.ShortPath}}">
+<label for="password">Password</label>
+<input type="password" id="password" name="password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// unlockURL handles the requests to the password-protected URL u.
+//
+// A GET request is responded with a form asking for the password.
+// A POST request submits the password in the form, and is redirected to u.URL with 303 See Other
+// if the password is correct, since the clients should not repost the password to u.URL.
+// The failed attempts are limited by app.passwordLimiter per client IP and short path,
+// and by app.passwordClientLimiter per client IP.
+func (app *App) unlockURL(w http.ResponseWriter, r *http.Request, u *data.URL) {
+	if r.Method != http.MethodPost {
+		app.passwordFormResponse(w, r, u, http.StatusOK, "", nil)
+		return
+	}
+
+	// Take the tokens for the attempt before checking the password, so that concurrent attempts cannot exceed the limits.
+	// The tokens are given back if the password is correct, so only the failed attempts are limited.
+	// The attempts are limited per client IP as well, so that the cost of checking the passwords is bounded per client.
+	ip := app.clientIP(r)
+	key, clientKey := "ip:"+ip+"/"+u.ShortPath, "ip:"+ip
+	ok, wait := app.passwordLimiter.allow(key)
+	if ok {
+		if ok, wait = app.passwordClientLimiter.allow(clientKey); !ok {
+			app.passwordLimiter.refund(key)
+			app.passwordClientLimiter.refund(clientKey)
+		}
+	}
+	if !ok {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		headers := http.Header{"Retry-After": []string{strconv.Itoa(retryAfter)}}
+		app.passwordFormResponse(w, r, u, http.StatusTooManyRequests, "Too many failed attempts, please try again later.", headers)
+		return
+	}
+
+	// Read the password from the form.
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormBody)
+	if err := r.ParseForm(); err != nil {
+		app.passwordFormResponse(w, r, u, http.StatusBadRequest, "Invalid form.", nil)
+		return
+	}
+	password := r.PostForm.Get("password")
+
+	// Check the password.
+	if len(password) > maxPasswordLen || !data.CheckPassword(u.PasswordHash, password) {
+		app.metrics.redirect(redirectDenied)
+		app.passwordFormResponse(w, r, u, http.StatusForbidden, "Incorrect password.", nil)
+		return
+	}
+	app.passwordLimiter.refund(key)
+	app.passwordClientLimiter.refund(clientKey)
+
+	app.redirectTo(w, r, u, http.StatusSeeOther)
+}
+
+// passwordFormResponse writes status, headers and the form asking for the password of u with the message errMsg.
+func (app *App) passwordFormResponse(w http.ResponseWriter, r *http.Request, u *data.URL, status int, errMsg string, headers http.Header) {
+	app.writeHTML(w, r, status, passwordFormTmpl, struct{ ShortPath, Error string }{u.ShortPath, errMsg}, headers)
+}
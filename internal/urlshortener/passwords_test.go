@@ -0,0 +1,194 @@
+package urlshortener
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+	"github.com/Kerseee/urlshortener/internal/data/mock"
+)
+
+// newPasswordRequest creates a request to the short path submitting password in the form.
+func newPasswordRequest(path, password string) *http.Request {
+	form := url.Values{"password": []string{password}}
+	r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/"+path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestRedirectProtected(t *testing.T) {
+	app, _ := newTestApp(t)
+	tests := []struct {
+		name         string
+		r            *http.Request
+		wantCode     int
+		wantBody     string
+		wantLocation string
+		wantClicks   int
+	}{
+		{
+			name:     "password form",
+			r:        httptest.NewRequest(http.MethodGet, "http://localhost:8080/Pr0tect3", nil),
+			wantCode: http.StatusOK,
+			wantBody: `<form method="post" action="/Pr0tect3">`,
+		},
+		{
+			name:     "wrong password",
+			r:        newPasswordRequest("Pr0tect3", "open-sesame!"),
+			wantCode: http.StatusForbidden,
+			wantBody: "Incorrect password.",
+		},
+		{
+			name:     "too long password",
+			r:        newPasswordRequest("Pr0tect3", strings.Repeat("a", maxPasswordLen+1)),
+			wantCode: http.StatusForbidden,
+			wantBody: "Incorrect password.",
+		},
+		{
+			name:         "correct password",
+			r:            newPasswordRequest("Pr0tect3", mock.Password),
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "https://pkg.go.dev",
+			wantClicks:   1,
+		},
+		{
+			name:     "invalid method",
+			r:        httptest.NewRequest(http.MethodPut, "http://localhost:8080/Pr0tect3", nil),
+			wantCode: http.StatusMethodNotAllowed,
+			wantBody: "this method is not allowed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clicks := &mock.ClickModel{}
+			app.clicks = newClickRecorder(10, 10, time.Second, clicks.InsertBatch, func(err error) { app.logError(nil, err) })
+			w := httptest.NewRecorder()
+			app.redirect(w, test.r)
+			app.clicks.close()
+
+			code, header, body := getResponse(t, w)
+			validateCode(t, test.wantCode, code)
+			validateBodyContains(t, test.wantBody, string(body))
+			if got := header.Get("Location"); got != test.wantLocation {
+				t.Errorf("want location %q, got %q", test.wantLocation, got)
+			}
+			if strings.Contains(string(body), "pkg.go.dev") && code != http.StatusSeeOther {
+				t.Errorf("the origin URL is revealed without password: %s", body)
+			}
+			if n := clicks.Len(); n != test.wantClicks {
+				t.Errorf("want %d recorded clicks, got %d", test.wantClicks, n)
+			}
+		})
+	}
+}
+
+// sendPassword submits password to the short path of app, and returns the response.
+func sendPassword(app *App, path, password string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	app.redirect(w, newPasswordRequest(path, password))
+	return w
+}
+
+func TestRedirectProtectedRateLimit(t *testing.T) {
+	app, _ := newTestApp(t)
+	l, now := newTestRateLimiter(1, 2)
+	app.passwordLimiter = l
+
+	submit := func(password string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.redirect(w, newPasswordRequest("Pr0tect3", password))
+		return w
+	}
+
+	// The burst of failed attempts is allowed.
+	for i := 0; i < 2; i++ {
+		validateCode(t, http.StatusForbidden, submit("wrong").Code)
+	}
+
+	// Further attempts are rejected, even with the correct password.
+	w := submit(mock.Password)
+	validateCode(t, http.StatusTooManyRequests, w.Code)
+	validateHeader(t, http.Header{"Retry-After": []string{"1"}}, w.Header())
+
+	// Other short paths are not affected.
+	if ok, _ := l.allow("ip:192.0.2.1/BQRvJsg-"); !ok {
+		t.Error("want another short path allowed")
+	}
+
+	// The correct password is accepted after the wait.
+	*now = now.Add(time.Second)
+	validateCode(t, http.StatusSeeOther, submit(mock.Password).Code)
+}
+
+func TestRedirectProtectedClientRateLimit(t *testing.T) {
+	app, _ := newTestApp(t)
+	l, _ := newTestRateLimiter(1, 2)
+	app.passwordClientLimiter = l
+
+	// The failed attempts of a client are limited across the protected URLs.
+	validateCode(t, http.StatusForbidden, sendPassword(app, "Pr0tect3", "wrong").Code)
+	validateCode(t, http.StatusForbidden, sendPassword(app, "Fl4gPr0t", "wrong").Code)
+	w := sendPassword(app, "Pr0tect3", mock.Password)
+	validateCode(t, http.StatusTooManyRequests, w.Code)
+	validateHeader(t, http.Header{"Retry-After": []string{"1"}}, w.Header())
+
+	// The attempt rejected per client is not counted against the URL.
+	if ok, _ := app.passwordLimiter.allowN("ip:192.0.2.1/Pr0tect3", 4); !ok {
+		t.Error("want the rejected attempt given back to the URL")
+	}
+}
+
+func TestRedirectProtectedRateLimitConcurrent(t *testing.T) {
+	app, _ := newTestApp(t)
+	l, _ := newTestRateLimiter(1, 5)
+	app.passwordLimiter = l
+
+	// Concurrent failed attempts cannot exceed the burst.
+	const attempts = 50
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			app.redirect(w, newPasswordRequest("Pr0tect3", "wrong"))
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	count := make(map[int]int)
+	for code := range codes {
+		count[code]++
+	}
+	if count[http.StatusForbidden] != 5 || count[http.StatusTooManyRequests] != attempts-5 {
+		t.Errorf("want 5 forbidden and %d too many requests, got %v", attempts-5, count)
+	}
+}
+
+func TestRegisterProtectedURL(t *testing.T) {
+	app, _ := newTestApp(t)
+	u, err := app.newURL(httptest.NewRequest(http.MethodPost, "/", nil), &urlInput{URL: "https://google.com", Password: mock.Password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.CheckPassword(u.PasswordHash, mock.Password) {
+		t.Fatalf("want the password hashed into the url, got %q", u.PasswordHash)
+	}
+
+	// The protected url does not reuse the unprotected short path of the same url.
+	if err := app.createURL(u, true); err != nil {
+		t.Fatal(err)
+	}
+	if u.ShortPath == "BQRvJsg-" {
+		t.Errorf("want a distinct short path from the unprotected one, got %q", u.ShortPath)
+	}
+}
@@ -0,0 +1,73 @@
+package urlshortener
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// previewTimeLayout is the layout of the times shown in the preview page.
+const previewTimeLayout = "2 Jan 2006 15:04 MST"
+
+// previewTmpl is the HTML page showing the destination of a shortened URL instead of the redirect.
+// The visitors continue to the short path with "?continue=1".
+// The destination of a password-protected URL is not shown.
+var previewTmpl = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link preview</title>
+</head>
+<body>
+<h1>Link preview</h1>
+<p><code>{{.ShortURL}}</code> leads to:</p>
+{{if .Protected}}<p>a site hidden behind a password.</p>
+{{else}}<p><code>{{.URL}}</code></p>
+{{end}}{{if .Flagged}}<p><strong>Warning:</strong> this site may be harmful, for example by stealing passwords or installing malware.</p>
+{{end}}<dl>
+{{with .CreatedAt}}<dt>Created</dt><dd>{{.}}</dd>
+{{end}}<dt>Expires</dt><dd>{{with .ExpireAt}}{{.}}{{else}}Never{{end}}</dd>
+</dl>
+<p><a href="/{{.ShortPath}}?continue=1" rel="nofollow">Continue to the site</a></p>
+</body>
+</html>
+`))
+
+// previewPage holds the data of previewTmpl.
+type previewPage struct {
+	ShortPath string
+	ShortURL  string
+	URL       string // empty if the URL is protected
+	Protected bool
+	Flagged   bool
+	CreatedAt string // empty if unknown
+	ExpireAt  string // empty if the URL never expires
+}
+
+// previewResponse writes the preview page of u.
+func (app *App) previewResponse(w http.ResponseWriter, r *http.Request, u *data.URL) {
+	page := previewPage{
+		ShortPath: u.ShortPath,
+		ShortURL:  app.shortURL(u.ShortPath),
+		Protected: u.Protected(),
+		Flagged:   u.Flagged,
+		CreatedAt: previewTime(u.CreatedAt),
+		ExpireAt:  previewTime(u.ExpireAt),
+	}
+	if !page.Protected {
+		page.URL = u.URL
+	}
+	app.writeHTML(w, r, http.StatusOK, previewTmpl, page, nil)
+}
+
+// previewTime formats t in UTC for the preview page, or returns "" if t is zero.
+func previewTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(previewTimeLayout)
+}
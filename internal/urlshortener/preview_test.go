@@ -0,0 +1,117 @@
+package urlshortener
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedirectPreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		wantCode int
+		wantBody []string
+		hidden   string // must not be in the body
+	}{
+		{
+			name:     "preview by plus",
+			method:   http.MethodGet,
+			target:   "/BQRvJsg-+",
+			wantCode: http.StatusOK,
+			wantBody: []string{"https://google.com", "22 Dec 2032 12:00 UTC", `href="/BQRvJsg-?continue=1"`},
+			hidden:   "Created",
+		},
+		{
+			name:     "preview by query",
+			method:   http.MethodGet,
+			target:   "/BQRvJsg-?preview=1",
+			wantCode: http.StatusOK,
+			wantBody: []string{"https://google.com"},
+		},
+		{
+			name:     "always previewed",
+			method:   http.MethodGet,
+			target:   "/Prev1ew1",
+			wantCode: http.StatusOK,
+			wantBody: []string{"https://go.dev/play", "Created", "1 Mar 2024 09:30 UTC", `href="/Prev1ew1?continue=1"`},
+		},
+		{
+			name:     "never expires",
+			method:   http.MethodGet,
+			target:   "/N3verExp+",
+			wantCode: http.StatusOK,
+			wantBody: []string{"https://go.dev", "Never"},
+		},
+		{
+			name:     "protected",
+			method:   http.MethodGet,
+			target:   "/Pr0tect3+",
+			wantCode: http.StatusOK,
+			wantBody: []string{"password"},
+			hidden:   "pkg.go.dev",
+		},
+		{
+			name:     "flagged",
+			method:   http.MethodGet,
+			target:   "/F1agged1+",
+			wantCode: http.StatusOK,
+			wantBody: []string{"https://sketchy.example", "Warning"},
+		},
+		{
+			name:     "expired",
+			method:   http.MethodGet,
+			target:   "/FGeTGg6M+",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "not found",
+			method:   http.MethodGet,
+			target:   "/notExist+",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "method not allowed",
+			method:   http.MethodPost,
+			target:   "/PermRedi+",
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	app, _ := newTestApp(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.redirect(w, httptest.NewRequest(test.method, "http://localhost:8080"+test.target, nil))
+
+			code, header, body := getResponse(t, w)
+			validateCode(t, test.wantCode, code)
+			if test.wantCode != http.StatusOK {
+				return
+			}
+			validateHeader(t, http.Header{"Content-Type": []string{"text/html; charset=utf-8"}}, header)
+			for _, want := range test.wantBody {
+				validateBodyContains(t, want, string(body))
+			}
+			if test.hidden != "" && strings.Contains(string(body), test.hidden) {
+				t.Errorf("want %q hidden from the body, got %q", test.hidden, body)
+			}
+			if loc := header.Get("Location"); loc != "" {
+				t.Errorf("want no redirect, got location %q", loc)
+			}
+		})
+	}
+}
+
+func TestRedirectAlwaysPreviewContinue(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	w := httptest.NewRecorder()
+	app.redirect(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080/Prev1ew1?continue=1", nil))
+	validateCode(t, app.config.Redirect.DefaultCode, w.Code)
+	if loc := w.Header().Get("Location"); loc != "https://go.dev/play" {
+		t.Errorf("want location https://go.dev/play, got %q", loc)
+	}
+}
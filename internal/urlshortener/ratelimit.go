@@ -0,0 +1,115 @@
+package urlshortener
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the minimum interval between two sweeps of the idle buckets of a rateLimiter.
+const sweepInterval = time.Minute
+
+// A rateLimiter limits the rate of events per key by the token bucket algorithm.
+// It is safe for concurrent use.
+//
+// Every key has a bucket holding at most burst tokens, which is refilled at rps tokens per second.
+// An event is allowed if it can take a token from the bucket of its key.
+// Buckets refilled to full are removed lazily, since a new bucket is full as well.
+type rateLimiter struct {
+	rps   float64
+	burst float64
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// A bucket holds the tokens of a key.
+type bucket struct {
+	tokens float64
+	last   time.Time // time tokens was last refilled
+}
+
+// newRateLimiter creates a rateLimiter allowing rps events per second with bursts of burst events per key.
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rps:     rps,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow reports whether an event of key is allowed.
+// If not, it also returns the time to wait until the next event of key is allowed.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	return l.allowN(key, 1)
+}
+
+// allowN reports whether n events of key are allowed at once, and only takes the tokens if all of them are allowed.
+// If not, it also returns the time to wait until the n events of key are allowed,
+// which is never if n exceeds the burst.
+func (l *rateLimiter) allowN(key string, n int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+
+	if b.tokens < float64(n) {
+		wait := time.Duration((float64(n) - b.tokens) / l.rps * float64(time.Second))
+		return false, wait
+	}
+	b.tokens -= float64(n)
+	return true, 0
+}
+
+// refund gives back a token taken from the bucket of key by allow, for an event which turns out not to count.
+func (l *rateLimiter) refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return
+	}
+	l.refill(b, l.now())
+	b.tokens = math.Min(l.burst, b.tokens+1)
+}
+
+// refill adds the tokens refilled since b.last into b. l.mu must be held.
+func (l *rateLimiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rps)
+	}
+	b.last = now
+}
+
+// sweep removes the buckets refilled to full, at most once per sweepInterval. l.mu must be held.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// len returns the number of buckets.
+func (l *rateLimiter) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
@@ -0,0 +1,71 @@
+package urlshortener
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestRateLimiter returns a rateLimiter with a controllable clock.
+func newTestRateLimiter(rps float64, burst int) (*rateLimiter, *time.Time) {
+	l := newRateLimiter(rps, burst)
+	now := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	l, now := newTestRateLimiter(2, 3)
+
+	// The burst is allowed.
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("a"); !ok {
+			t.Fatalf("want request %d allowed", i+1)
+		}
+	}
+
+	// The bucket is empty, and a token is refilled in half a second.
+	ok, wait := l.allow("a")
+	if ok {
+		t.Fatal("want request exceeding the burst rejected")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("want wait 500ms, got %v", wait)
+	}
+
+	// Other keys have their own buckets.
+	if ok, _ := l.allow("b"); !ok {
+		t.Error("want request of another key allowed")
+	}
+
+	// A token is refilled.
+	*now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.allow("a"); !ok {
+		t.Error("want request allowed after refilling")
+	}
+	if ok, _ := l.allow("a"); ok {
+		t.Error("want request rejected after taking the refilled token")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	l, now := newTestRateLimiter(1, 5)
+	l.allow("idle")
+	*now = now.Add(2 * time.Second)
+	for i := 0; i < 5; i++ {
+		l.allow("busy")
+	}
+	if n := l.len(); n != 2 {
+		t.Fatalf("want 2 buckets, got %d", n)
+	}
+
+	// After sweepInterval, the refilled bucket of "idle" is removed but "busy" is kept.
+	*now = now.Add(sweepInterval - time.Second)
+	for i := 0; i < 5; i++ {
+		l.allow("busy")
+	}
+	*now = now.Add(time.Second)
+	l.allow("busy")
+	if n := l.len(); n != 1 {
+		t.Errorf("want 1 bucket after sweeping, got %d", n)
+	}
+}
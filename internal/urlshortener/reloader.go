@@ -0,0 +1,55 @@
+package urlshortener
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// startReloader runs reload on SIGHUP in background until ctx is done or the returned stop function is called.
+// The reloader is not run if there is nothing to reload.
+func (app *App) startReloader(ctx context.Context) (stop func()) {
+	if app.domains == nil && app.hashList == nil {
+		return func() {}
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				app.reload()
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(hup)
+		cancel()
+		<-done
+	}
+}
+
+// reload reloads the domain lists and the hash list of the scanner from their files.
+// A list which cannot be reloaded is kept, and the error is logged.
+func (app *App) reload() {
+	if app.domains != nil {
+		if err := app.domains.reload(); err != nil {
+			app.logError(nil, err)
+		} else {
+			app.logInfo("Reloaded domain lists")
+		}
+	}
+	if app.hashList != nil {
+		if err := app.hashList.Reload(); err != nil {
+			app.logError(nil, err)
+		} else {
+			app.logInfo("Reloaded scanner hash list")
+		}
+	}
+}
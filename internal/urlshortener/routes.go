@@ -2,10 +2,15 @@ package urlshortener
 
 import "net/http"
 
-// routes creates and returns a http servemux.
+// routes creates and returns a http servemux wrapped by the middlewares.
 func (app *App) routes() http.Handler {
 	mux := &http.ServeMux{}
-	mux.HandleFunc("/", app.redirect)
-	mux.HandleFunc("/api/v1/urls", app.registerURL)
-	return mux
+	mux.Handle("/", app.rateLimit(app.redirectLimiter, http.HandlerFunc(app.redirect)))
+	mux.Handle("/api/v1/urls", app.authenticate(app.rateLimit(app.createLimiter, http.HandlerFunc(app.registerURL))))
+	mux.Handle("/api/v1/urls/", app.authenticate(http.HandlerFunc(app.manageURL)))
+	mux.Handle(batchPath, app.authenticate(app.rateLimit(app.createLimiter, http.HandlerFunc(app.registerURLs))))
+	mux.HandleFunc("/healthz", app.healthz)
+	mux.HandleFunc("/readyz", app.readyz)
+	mux.HandleFunc("/metrics", app.showMetrics)
+	return app.requestID(app.instrument(mux))
 }
@@ -0,0 +1,87 @@
+package urlshortener
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+	"github.com/Kerseee/urlshortener/internal/scanner"
+)
+
+var (
+	errMaliciousURL    = errors.New("url is rejected as malicious")
+	errScanUnavailable = errors.New("url cannot be scanned now, please try again later")
+)
+
+// warningTmpl is the HTML interstitial page warning the visitors of a flagged URL before the redirect.
+// The visitors continue to the short path with "?continue=1".
+// The destination of a password-protected URL is not shown.
+var warningTmpl = template.Must(template.New("warning").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Warning: suspicious link</title>
+</head>
+<body>
+<h1>Warning: suspicious link</h1>
+<p>This link leads to a site which may be harmful, for example by stealing passwords or installing malware:</p>
+{{if .Protected}}<p>a site hidden behind a password.</p>
+{{else}}<p><code>{{.URL}}</code></p>
+{{end}}
+<p>Only continue if you trust the site.</p>
+<p><a href="/{{.ShortPath}}?continue=1" rel="nofollow">Continue to the site</a></p>
+</body>
+</html>
+`))
+
+// scanURL scans u.URL by app.urlScanner, and sets u.Flagged if u.URL is suspicious.
+//
+// It returns errMaliciousURL if u.URL is malicious.
+// If u.URL cannot be scanned, the error is logged, and errScanUnavailable is returned if app.config.Scanner.FailClosed.
+// Otherwise, u is accepted with the verdict of the scanners succeeding.
+func (app *App) scanURL(r *http.Request, u *data.URL) error {
+	u.Flagged = false
+	if app.urlScanner == nil {
+		return nil
+	}
+
+	ctx := r.Context()
+	if app.config.Scanner.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, app.config.Scanner.Timeout)
+		defer cancel()
+	}
+	verdict, err := app.urlScanner.Scan(ctx, u.URL)
+	if err != nil {
+		app.logError(r, err)
+		if app.config.Scanner.FailClosed {
+			return errScanUnavailable
+		}
+	}
+
+	switch verdict {
+	case scanner.Malicious:
+		app.logInfo("Rejected malicious url", "url", u.URL)
+		return errMaliciousURL
+	case scanner.Suspicious:
+		u.Flagged = true
+	}
+	return nil
+}
+
+// warningResponse writes the interstitial page warning the visitors of the flagged URL u.
+func (app *App) warningResponse(w http.ResponseWriter, r *http.Request, u *data.URL) {
+	page := struct {
+		ShortPath string
+		URL       string // empty if the URL is protected
+		Protected bool
+	}{ShortPath: u.ShortPath, Protected: u.Protected()}
+	if !page.Protected {
+		page.URL = u.URL
+	}
+	app.writeHTML(w, r, http.StatusOK, warningTmpl, page, nil)
+}
@@ -0,0 +1,190 @@
+package urlshortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+	"github.com/Kerseee/urlshortener/internal/scanner"
+)
+
+// stubScanner is a scanner.Scanner returning verdict and err.
+type stubScanner struct {
+	verdict scanner.Verdict
+	err     error
+}
+
+func (s stubScanner) Scan(ctx context.Context, rawURL string) (scanner.Verdict, error) {
+	return s.verdict, s.err
+}
+
+func TestScanURL(t *testing.T) {
+	errUnavailable := errors.New("scanner unavailable")
+	tests := []struct {
+		name        string
+		scanner     scanner.Scanner
+		failClosed  bool
+		wantErr     error
+		wantFlagged bool
+	}{
+		{"no scanner", nil, false, nil, false},
+		{"safe", stubScanner{scanner.Safe, nil}, false, nil, false},
+		{"suspicious", stubScanner{scanner.Suspicious, nil}, false, nil, true},
+		{"malicious", stubScanner{scanner.Malicious, nil}, false, errMaliciousURL, false},
+		{"fail open", stubScanner{scanner.Safe, errUnavailable}, false, nil, false},
+		{"fail open with suspicious verdict of other scanners", stubScanner{scanner.Suspicious, errUnavailable}, false, nil, true},
+		{"fail closed", stubScanner{scanner.Safe, errUnavailable}, true, errScanUnavailable, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, logger := newTestApp(t)
+			app.urlScanner = test.scanner
+			app.config.Scanner.FailClosed = test.failClosed
+
+			u := &data.URL{URL: "https://example.com", Flagged: true}
+			err := app.scanURL(httptest.NewRequest(http.MethodPost, "/api/v1/urls", nil), u)
+			if !errors.Is(err, test.wantErr) || (test.wantErr == nil && err != nil) {
+				t.Errorf("want error %v, got %v", test.wantErr, err)
+			}
+			if u.Flagged != test.wantFlagged {
+				t.Errorf("want flagged %v, got %v", test.wantFlagged, u.Flagged)
+			}
+			if s, ok := test.scanner.(stubScanner); ok && s.err != nil {
+				validateBodyContains(t, "scanner unavailable", logger.String())
+			}
+		})
+	}
+}
+
+func TestRegisterURLScan(t *testing.T) {
+	tests := []struct {
+		name       string
+		scanner    scanner.Scanner
+		failClosed bool
+		wantCode   int
+		wantBody   string
+	}{
+		{"safe", stubScanner{scanner.Safe, nil}, false, http.StatusOK, "shortUrl"},
+		{"suspicious", stubScanner{scanner.Suspicious, nil}, false, http.StatusOK, "shortUrl"},
+		{"malicious", stubScanner{scanner.Malicious, nil}, false, http.StatusBadRequest, "url is rejected as malicious"},
+		{"fail open", stubScanner{scanner.Safe, errors.New("timeout")}, false, http.StatusOK, "shortUrl"},
+		{"fail closed", stubScanner{scanner.Safe, errors.New("timeout")}, true, http.StatusServiceUnavailable, "url cannot be scanned now"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app, _ := newTestApp(t)
+			app.urlScanner = test.scanner
+			app.config.Scanner.FailClosed = test.failClosed
+
+			body := `{"url":"https://facebook.com", "expireAt":"2033-12-22T12:00:00Z"}`
+			r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/urls", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			app.registerURL(w, r)
+
+			code, _, respBody := getResponse(t, w)
+			validateCode(t, test.wantCode, code)
+			validateBodyContains(t, test.wantBody, string(respBody))
+		})
+	}
+}
+
+func TestRedirectFlagged(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	// The visitors are warned.
+	w := httptest.NewRecorder()
+	app.redirect(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080/F1agged1", nil))
+	code, header, body := getResponse(t, w)
+	validateCode(t, http.StatusOK, code)
+	validateHeader(t, http.Header{"Content-Type": []string{"text/html; charset=utf-8"}}, header)
+	validateBodyContains(t, "https://sketchy.example", string(body))
+	validateBodyContains(t, `href="/F1agged1?continue=1"`, string(body))
+
+	// The visitors choosing to continue are redirected.
+	w = httptest.NewRecorder()
+	app.redirect(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080/F1agged1?continue=1", nil))
+	validateCode(t, http.StatusSeeOther, w.Code)
+	if loc := w.Header().Get("Location"); loc != "https://sketchy.example" {
+		t.Errorf("want location https://sketchy.example, got %q", loc)
+	}
+}
+
+func TestRedirectFlaggedProtected(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	// The visitors are warned without revealing the origin URL.
+	w := httptest.NewRecorder()
+	app.redirect(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080/Fl4gPr0t", nil))
+	code, _, body := getResponse(t, w)
+	validateCode(t, http.StatusOK, code)
+	validateBodyContains(t, `href="/Fl4gPr0t?continue=1"`, string(body))
+	if strings.Contains(string(body), "hidden.example") {
+		t.Errorf("the origin URL is revealed without password: %s", body)
+	}
+
+	// The visitors choosing to continue are asked for the password.
+	w = httptest.NewRecorder()
+	app.redirect(w, httptest.NewRequest(http.MethodGet, "http://localhost:8080/Fl4gPr0t?continue=1", nil))
+	code, _, body = getResponse(t, w)
+	validateCode(t, http.StatusOK, code)
+	validateBodyContains(t, `<form method="post" action="/Fl4gPr0t">`, string(body))
+	if strings.Contains(string(body), "hidden.example") {
+		t.Errorf("the origin URL is revealed without password: %s", body)
+	}
+}
+
+// blockingScanner is a scanner.Scanner which accepts the first URL, and blocks the others until ctx is done.
+type blockingScanner struct {
+	calls atomic.Int32
+}
+
+func (s *blockingScanner) Scan(ctx context.Context, rawURL string) (scanner.Verdict, error) {
+	if s.calls.Add(1) == 1 {
+		return scanner.Safe, nil
+	}
+	<-ctx.Done()
+	return scanner.Safe, ctx.Err()
+}
+
+func TestRegisterURLsTimeout(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.urlScanner = &blockingScanner{}
+	app.config.BatchTimeout = 50 * time.Millisecond
+
+	body := `[{"url":"https://facebook.com"},{"url":"https://netflix.com"},{"url":"https://github.com"}]`
+	r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/urls/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	app.registerURLs(w, r)
+
+	code, _, respBody := getResponse(t, w)
+	validateCode(t, http.StatusOK, code)
+	var resp struct {
+		Results []struct {
+			ID    string   `json:"id"`
+			Error []string `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 3 {
+		t.Fatalf("want 3 results, got %s", respBody)
+	}
+
+	// The urls scanned after the deadline are not shortened.
+	if resp.Results[0].ID == "" {
+		t.Errorf("want the first url shortened, got %s", respBody)
+	}
+	for _, result := range resp.Results[1:] {
+		if result.ID != "" || len(result.Error) != 1 || result.Error[0] != errBatchTimeout.Error() {
+			t.Errorf("want the url timed out, got %+v", result)
+		}
+	}
+}
@@ -1,11 +1,18 @@
 /*
 Package urlshortener provides the url shortener application.
 
-The url shortener application has two APIs. One is to shorten a url,
-and the other is to redirect the shortened URL to the origin url.
+The url shortener application has APIs to shorten a url, to manage the shortened URLs,
+and to redirect the shortened URL to the origin url.
 
 End point "/api/v1/urls" handles json-encoded POST requests and shorten urls.
+End point "/api/v1/urls/batch" handles json-encoded POST requests and shorten a batch of urls.
+End point "/api/v1/urls/:id" handles GET, PATCH and DELETE requests and manages the shortened URL.
+End point "/api/v1/urls/:id/stats" handles GET requests and reports the clicks of the shortened URL.
 End point "/:shortenedURL" handles GET requests and redirect to the origin url.
+A password-protected shortened URL serves a form on GET requests and redirects on POST requests with the password.
+End points "/healthz" and "/readyz" handle GET requests and report the liveness and readiness of the server.
+End point "/metrics" handles GET requests and exposes the metrics in the Prometheus text format.
+End points under "/api/v1/" are authenticated by API keys, which are created by CreateAPIKey.
 
 To create a url shortener application:
 
@@ -22,8 +29,13 @@ To create a url shortener application:
 		if err != nil {
 			// handle error
 		}
-		log.Fatal(app.Serve())
+		if err := app.Serve(); err != nil {
+			log.Fatal(err)
+		}
 	}
+
+Serve shuts down the server gracefully on SIGINT or SIGTERM and returns nil after a clean shutdown.
+It reloads the domain lists and the scanner hash list on SIGHUP.
 */
 
 package urlshortener
@@ -31,51 +43,252 @@ package urlshortener
 import (
 	"context"
 	"database/sql"
-	"fmt"
-	"log"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/Kerseee/urlshortener/config"
 	"github.com/Kerseee/urlshortener/internal/data"
+	"github.com/Kerseee/urlshortener/internal/scanner"
 )
 
 // An App is a url shortener application.
 type App struct {
 	config config.Config // see package config
-	logger *log.Logger
+	logger *slog.Logger
 
-	// A urlModel is a model for executing queries to the urls table in the DB.
-	urlModel interface {
-		Get(s string) (*data.URL, error)
-		Insert(u *data.URL) error
-		Update(u *data.URL) error
-	}
+	// A urlModel is a model for storing the shortened URLs in the storage backend.
+	urlModel data.Store
+
+	// A clickModel is a model for storing the clicks in the storage backend.
+	clickModel data.ClickStore
+
+	// codes generates the short paths of the URLs.
+	codes codeGenerator
+
+	// An apiKeyModel is a model for storing the API keys in the storage backend.
+	apiKeyModel data.APIKeyStore
+
+	// createLimiter and redirectLimiter limit the rates of shortening URLs and redirects.
+	// They are nil if rate limiting is disabled.
+	createLimiter   *rateLimiter
+	redirectLimiter *rateLimiter
+
+	// passwordLimiter limits the rate of failed password attempts per client IP and protected URL,
+	// and passwordClientLimiter limits them per client IP.
+	// They are never disabled, since they protect the passwords from brute force.
+	passwordLimiter       *rateLimiter
+	passwordClientLimiter *rateLimiter
+
+	// domains are the blocked and allowed domains of the destination URLs, nil if there is no domain list.
+	domains *domainLists
+
+	// urlScanner scans the URLs to be shortened, nil if there is no scanner.
+	// hashList is the scanner reading the hash list file, nil if there is no hash list.
+	urlScanner scanner.Scanner
+	hashList   *scanner.HashList
+
+	// placeholder is the HTML page served for the shortened URLs not active yet, nil for 404 Not Found.
+	placeholder []byte
+
+	// clicks records the clicks into clickModel in background.
+	clicks *clickRecorder
+
+	// metrics collects the metrics exposed at "/metrics".
+	metrics *metrics
+
+	// closer closes the storage backend, nil if there is nothing to close.
+	closer io.Closer
+
+	// db is the database connection pool of the postgres storage backend, nil for other backends.
+	db *sql.DB
+
+	// shuttingDown is set to 1 when the server starts shutting down. It is accessed atomically.
+	shuttingDown int32
 }
 
-// New creates and returns an application instance including opened database connection pool.
+// New creates and returns an application instance including opened storage backend.
 func New(conf config.Config) (*App, error) {
-	db, err := OpenDB(conf)
-	if err != nil {
+	app := &App{
+		config:  conf,
+		logger:  newLogger(os.Stderr, conf.Log.Format, conf.Log.Level),
+		metrics: newMetrics(),
+	}
+	if conf.Redirect.PlaceholderPage != "" {
+		page, err := os.ReadFile(conf.Redirect.PlaceholderPage)
+		if err != nil {
+			return nil, err
+		}
+		app.placeholder = page
+	}
+	if conf.Destination.BlocklistPath != "" || conf.Destination.AllowlistPath != "" {
+		domains, err := newDomainLists(conf.Destination.BlocklistPath, conf.Destination.AllowlistPath)
+		if err != nil {
+			return nil, err
+		}
+		app.domains = domains
+	}
+	if err := app.openScanners(); err != nil {
 		return nil, err
 	}
-	app := &App{
-		config:   conf,
-		logger:   log.Default(),
-		urlModel: &data.URLModel{DB: db, QueryTimeOut: conf.DB.QueryTimeout},
+	if err := app.openStorage(); err != nil {
+		return nil, err
+	}
+	if conf.Cache.Size > 0 {
+		app.urlModel = newURLCache(app.urlModel, conf.Cache.Size, conf.Cache.TTL, conf.Cache.NegativeTTL)
 	}
-	app.logInfo("Database connection established!")
+	app.codes = newCodeGenerator(conf.ShortURL.Generator, app.urlModel)
+	if conf.RateLimit.Enabled {
+		app.createLimiter = newRateLimiter(conf.RateLimit.Create.RPS, conf.RateLimit.Create.Burst)
+		app.redirectLimiter = newRateLimiter(conf.RateLimit.Redirect.RPS, conf.RateLimit.Redirect.Burst)
+	}
+	app.passwordLimiter = newRateLimiter(conf.RateLimit.Password.RPS, conf.RateLimit.Password.Burst)
+	app.passwordClientLimiter = newRateLimiter(conf.RateLimit.PasswordClient.RPS, conf.RateLimit.PasswordClient.Burst)
+	app.clicks = newClickRecorder(conf.Clicks.QueueSize, conf.Clicks.BatchSize, conf.Clicks.FlushInterval,
+		app.clickModel.InsertBatch, func(err error) { app.logError(nil, err) })
 	return app, nil
 }
 
-// Serve opens a http server and serves http requests.
+// openScanners opens the scanners configured in app.config.Scanner into app.urlScanner.
+func (app *App) openScanners() error {
+	var scanners scanner.Multi
+	if path := app.config.Scanner.HashListPath; path != "" {
+		l, err := scanner.OpenHashList(path)
+		if err != nil {
+			return err
+		}
+		app.hashList = l
+		scanners = append(scanners, l)
+	}
+	if url := app.config.Scanner.WebhookURL; url != "" {
+		scanners = append(scanners, scanner.NewWebhook(url, app.config.Scanner.Timeout))
+	}
+	switch len(scanners) {
+	case 0:
+	case 1:
+		app.urlScanner = scanners[0]
+	default:
+		app.urlScanner = scanners
+	}
+	return nil
+}
+
+// openStorage opens the storage backend selected by app.config.Storage.Backend.
+func (app *App) openStorage() error {
+	switch app.config.Storage.Backend {
+	case config.StorageMemory:
+		store := data.NewMemoryStore()
+		app.urlModel = store
+		app.apiKeyModel = store.APIKeys()
+		app.clickModel = store.Clicks()
+		app.logInfo("Using in-memory storage, all data will be lost when the server stops")
+	case config.StorageFile:
+		store, err := data.OpenFileStore(app.config.Storage.Path)
+		if err != nil {
+			return err
+		}
+		app.urlModel = store
+		app.apiKeyModel = store.APIKeys()
+		app.clickModel = store.Clicks()
+		app.closer = store
+		app.logInfo("Using file storage, clicks are kept in memory", "path", app.config.Storage.Path)
+	default:
+		db, err := OpenDB(app.config)
+		if err != nil {
+			return err
+		}
+		app.urlModel = &data.URLModel{DB: db, QueryTimeOut: app.config.DB.QueryTimeout}
+		app.clickModel = &data.ClickModel{DB: db, QueryTimeOut: app.config.DB.QueryTimeout}
+		app.apiKeyModel = &data.APIKeyModel{DB: db, QueryTimeOut: app.config.DB.QueryTimeout}
+		app.closer = db
+		app.db = db
+		app.logInfo("Database connection established!")
+	}
+	return nil
+}
+
+// Serve opens a http server and serves http requests until SIGINT or SIGTERM is received.
+//
+// On SIGINT or SIGTERM, Serve stops accepting new requests, waits for the in-flight requests
+// for at most app.config.ShutdownTimeout, flushes the background work and closes the storage backend.
+// It returns nil after a clean shutdown.
 func (app *App) Serve() error {
-	app.logInfo(fmt.Sprintf("Start server at %s\n", app.config.Addr))
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	l, err := net.Listen("tcp", app.config.Addr)
+	if err != nil {
+		app.Close()
+		return err
+	}
+	return app.serve(ctx, l)
+}
+
+// serve serves http requests on l until ctx is done, and then shuts down the server gracefully.
+func (app *App) serve(ctx context.Context, l net.Listener) error {
+	app.logInfo("Start server", "addr", l.Addr().String())
 	server := &http.Server{
 		Addr:    app.config.Addr,
 		Handler: app.routes(),
 	}
-	return server.ListenAndServe()
+
+	// Purge the expired URLs and reload the lists on SIGHUP in background.
+	stopJanitor := app.startJanitor(ctx)
+	stopReloader := app.startReloader(ctx)
+
+	// Shut down the server when ctx is done.
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		atomic.StoreInt32(&app.shuttingDown, 1)
+		app.logInfo("Shutting down server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), app.config.ShutdownTimeout)
+		defer cancel()
+		shutdownErr <- server.Shutdown(shutdownCtx)
+	}()
+
+	err := server.Serve(l)
+	if !errors.Is(err, http.ErrServerClosed) {
+		stopJanitor()
+		stopReloader()
+		app.Close()
+		return err
+	}
+
+	// Wait for the in-flight requests.
+	err = <-shutdownErr
+	stopJanitor()
+	stopReloader()
+	app.Close()
+	if err != nil {
+		return err
+	}
+	app.logInfo("Server stopped")
+	return nil
+}
+
+// Close flushes the background work and closes the storage backend.
+// It is called by Serve, and should be called by the applications not calling Serve.
+func (app *App) Close() {
+	// Flush the clicks waiting to be recorded.
+	app.clicks.close()
+	if n := app.clicks.droppedClicks(); n > 0 {
+		app.logInfo("Clicks were dropped", "dropped_clicks", n)
+	}
+
+	// Close the storage backend.
+	if app.closer != nil {
+		if err := app.closer.Close(); err != nil {
+			app.logError(nil, err)
+		}
+	}
 }
 
 // OpenDB creates a database connection pool and executes first ping for checking connections.
@@ -101,3 +314,19 @@ func OpenDB(conf config.Config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// CreateAPIKey creates an API key named name and returns the plaintext key,
+// which cannot be retrieved again since only its hash is stored.
+func (app *App) CreateAPIKey(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("name of the API key should not be empty")
+	}
+	k, plaintext, err := data.NewAPIKey(name)
+	if err != nil {
+		return "", err
+	}
+	if err := app.apiKeyModel.Insert(k); err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,67 @@
+package urlshortener
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Kerseee/urlshortener/internal/data"
+)
+
+// closerFunc is an io.Closer calling itself.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+func TestServeShutdown(t *testing.T) {
+	app, _ := newTestApp(t)
+	app.config.ShutdownTimeout = time.Second
+	closed := false
+	app.closer = closerFunc(func() error {
+		closed = true
+		return nil
+	})
+
+	// Start the server.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.serve(ctx, l)
+	}()
+
+	// The server is serving requests.
+	resp, err := http.Get("http://" + l.Addr().String() + "/BQRvJsg-/not-exist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	validateCode(t, http.StatusNotFound, resp.StatusCode)
+
+	// Shut down the server.
+	cancel()
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			t.Errorf("want nil error after shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want server stopped, got still serving")
+	}
+	if !closed {
+		t.Error("want storage closed, got not closed")
+	}
+	if app.clicks.record(&data.Click{URLID: 1}) {
+		t.Error("want click recorder closed, got still recording")
+	}
+	if _, err := http.Get("http://" + l.Addr().String() + "/BQRvJsg-"); err == nil {
+		t.Error("want connection refused after shutdown, got nil error")
+	}
+}
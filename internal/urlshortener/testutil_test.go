@@ -3,35 +3,43 @@ package urlshortener
 import (
 	"bytes"
 	"io"
-	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Kerseee/urlshortener/config"
 	"github.com/Kerseee/urlshortener/internal/data/mock"
 )
 
-// newTestServer returns a pointer point to an App instance and a bytes.Buffer as logger.
-func newTestApp() (*App, *bytes.Buffer) {
+// newTestApp returns a pointer point to an App instance and a bytes.Buffer as logger.
+// The click recorder of the App is closed when t finishes.
+func newTestApp(t *testing.T) (*App, *bytes.Buffer) {
 	conf := config.Config{
 		Addr: "http://localhost:8080",
-		ShortURL: struct {
-			Len             int
-			MaxReShortenLen int
-		}{
-			Len:             8,
-			MaxReShortenLen: 12,
-		},
 	}
+	conf.ShortURL.Len = 8
+	conf.ShortURL.MaxReShortenLen = 12
+
+	conf.Redirect.DefaultCode = http.StatusSeeOther
 
 	logger := bytes.Buffer{}
-	return &App{
-		config:   conf,
-		logger:   log.New(&logger, "", 0),
-		urlModel: &mock.URLModel{},
-	}, &logger
+	app := &App{
+		config:      conf,
+		logger:      newLogger(&logger, logFormatJSON, "debug"),
+		urlModel:    &mock.URLModel{},
+		clickModel:  &mock.ClickModel{},
+		apiKeyModel: &mock.APIKeyModel{},
+		metrics:     newMetrics(),
+		codes:       hashGenerator{},
+
+		passwordLimiter:       newRateLimiter(0.1, 5),
+		passwordClientLimiter: newRateLimiter(0.5, 20),
+	}
+	app.clicks = newClickRecorder(100, 10, time.Second, app.clickModel.InsertBatch, func(err error) { app.logError(nil, err) })
+	t.Cleanup(app.clicks.close)
+	return app, &logger
 }
 
 // stringSet creates a set of string containing values in slice s.
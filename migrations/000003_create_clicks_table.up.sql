@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS clicks (
+    id bigserial PRIMARY KEY,
+    url_id bigint NOT NULL REFERENCES urls ON DELETE CASCADE,
+    short_url text NOT NULL,
+    clicked_at timestamp with time zone NOT NULL,
+    referrer text NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    client_ip text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS clicks_url_id_clicked_at_index ON clicks (url_id, clicked_at);
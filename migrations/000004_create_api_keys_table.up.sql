@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    key_hash bytea UNIQUE NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
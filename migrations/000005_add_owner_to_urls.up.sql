@@ -0,0 +1 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS owner_id bigint REFERENCES api_keys ON DELETE SET NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS redirect_code;
\ No newline at end of file
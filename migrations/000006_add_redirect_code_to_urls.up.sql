@@ -0,0 +1 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_code smallint NOT NULL DEFAULT 0 CHECK (redirect_code IN (0, 301, 302, 303, 307, 308));
\ No newline at end of file
@@ -0,0 +1,2 @@
+UPDATE urls SET expire_at = '9999-12-31 23:59:59+00' WHERE expire_at IS NULL;
+ALTER TABLE urls ALTER COLUMN expire_at SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE urls ALTER COLUMN expire_at DROP NOT NULL;
\ No newline at end of file
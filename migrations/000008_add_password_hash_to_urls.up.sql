@@ -0,0 +1 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash text;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE urls
+	DROP COLUMN IF EXISTS click_count,
+	DROP COLUMN IF EXISTS max_clicks;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE urls
+	ADD COLUMN IF NOT EXISTS max_clicks bigint CHECK (max_clicks > 0),
+	ADD COLUMN IF NOT EXISTS click_count bigint NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS active_from;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS active_from timestamp with time zone;
\ No newline at end of file
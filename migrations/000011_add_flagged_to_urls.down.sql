@@ -0,0 +1 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS flagged;
\ No newline at end of file
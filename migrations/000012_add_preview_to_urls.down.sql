@@ -0,0 +1,2 @@
+ALTER TABLE urls DROP COLUMN IF EXISTS created_at;
+ALTER TABLE urls DROP COLUMN IF EXISTS always_preview;
\ No newline at end of file
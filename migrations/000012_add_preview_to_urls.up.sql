@@ -0,0 +1,3 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS always_preview boolean NOT NULL DEFAULT false;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS created_at timestamp with time zone;
+ALTER TABLE urls ALTER COLUMN created_at SET DEFAULT now();
\ No newline at end of file
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS expire_at_index;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS expire_at_index ON urls (expire_at);
\ No newline at end of file